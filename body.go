@@ -0,0 +1,75 @@
+package webanalyze
+
+import (
+	"io"
+	"io/ioutil"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// defaultMaxBodySize is the number of bytes read from a response body
+// unless configured otherwise with WithMaxBodySize
+const defaultMaxBodySize = 10 << 20
+
+// readBody reads a response body up to the configured maximum size and
+// reports whether the body was truncated
+func (wa *WebAnalyzer) readBody(body io.Reader) ([]byte, bool, error) {
+	if wa.maxBodySize <= 0 {
+		data, err := ioutil.ReadAll(body)
+		return data, false, err
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(body, wa.maxBodySize+1))
+	if int64(len(data)) > wa.maxBodySize {
+		return data[:wa.maxBodySize], true, err
+	}
+	return data, false, err
+}
+
+// isTextual reports whether a content type may contain markup or scripts.
+// Missing or invalid content types are considered textual.
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "javascript"),
+		strings.HasSuffix(mediaType, "ecmascript"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/xml", "application/json":
+		return true
+	}
+
+	return false
+}
+
+// decodeBody converts a body to UTF-8 based on the charset of the content
+// type, a byte order mark or a meta tag. Bodies which are valid UTF-8 and
+// don't declare another charset are returned unchanged.
+func decodeBody(body []byte, contentType string) []byte {
+	e, name, certain := charset.DetermineEncoding(body, contentType)
+	if name == "utf-8" || (!certain && utf8.Valid(body)) {
+		return body
+	}
+
+	decoded, err := e.NewDecoder().Bytes(body)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
@@ -0,0 +1,114 @@
+package webanalyze
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsTextual(t *testing.T) {
+	cases := map[string]bool{
+		"":                         true,
+		"text/html; charset=utf-8": true,
+		"application/xhtml+xml":    true,
+		"application/javascript":   true,
+		"application/json":         true,
+		"image/png":                false,
+		"application/octet-stream": false,
+		"video/mp4":                false,
+		"invalid/;;":               true,
+	}
+
+	for contentType, expected := range cases {
+		if isTextual(contentType) != expected {
+			t.Fatalf("%q: expected %v", contentType, expected)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	latin1 := []byte("<html><body>Caf\xe9</body></html>")
+
+	if s := string(decodeBody(latin1, "text/html; charset=iso-8859-1")); !strings.Contains(s, "Café") {
+		t.Fatalf("latin1 body not decoded: %q", s)
+	}
+
+	utf := []byte("<html><body>Café</body></html>")
+	if s := string(decodeBody(utf, "text/html")); s != string(utf) {
+		t.Fatalf("utf-8 body was modified: %q", s)
+	}
+}
+
+func TestProcessBodyHandling(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "Foo")
+		if r.URL.Path == "/binary" {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		fmt.Fprint(w, "foo-html "+strings.Repeat("x", 2000))
+	}))
+	defer srv.Close()
+
+	defs := `{"categories": {}, "technologies": {
+		"Header": {"headers": {"X-Powered-By": "Foo"}},
+		"Html": {"html": "foo-html"}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithMaxBodySize(1000))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL+"/binary", "", nil, 0, false, false))
+	names := matchNames(result.Matches)
+	if !names["Header"] || names["Html"] {
+		t.Fatalf("binary content: unexpected matches %v", names)
+	}
+
+	result, _ = wa.Process(NewOnlineJob(srv.URL+"/page", "", nil, 0, false, false))
+	names = matchNames(result.Matches)
+	if !names["Header"] || !names["Html"] {
+		t.Fatalf("text content: unexpected matches %v", names)
+	}
+	if !result.Truncated {
+		t.Fatalf("truncated body not flagged")
+	}
+}
+
+func TestProcessBodyReadTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Powered-By", "Foo")
+		fmt.Fprint(w, "<html>foo-html")
+		w.(http.Flusher).Flush()
+
+		// stall the rest of the body past the request timeout
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	defs := `{"categories": {}, "technologies": {
+		"Header": {"headers": {"X-Powered-By": "Foo"}},
+		"Html": {"html": "foo-html"}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+
+	names := matchNames(result.Matches)
+	if !names["Header"] || !names["Html"] {
+		t.Fatalf("partial response: unexpected matches %v", names)
+	}
+	if !errors.Is(result.Error, ErrTimeout) {
+		t.Fatalf("expected timeout error, got %v", result.Error)
+	}
+}
@@ -0,0 +1,104 @@
+package webanalyze
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lruCache stores the results of lookups which should only run once per
+// key, like the dns records of a domain. Concurrent lookups of a key wait
+// for the first one. Failed lookups are not cached, so the next call
+// retries them. Entries expire after ttl and the least recently used
+// entries are evicted once the cache holds more than size entries.
+type lruCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+type cacheEntry struct {
+	key     string
+	done    chan struct{} // closed once the lookup finished
+	value   interface{}
+	ok      bool
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get returns the cached value of key, calling load if there is none.
+// load reports whether its value may be cached.
+func (c *lruCache) get(key string, load func() (interface{}, bool)) interface{} {
+	for {
+		c.mu.Lock()
+		elem, ok := c.entries[key]
+		if ok && c.expired(elem.Value.(*cacheEntry)) {
+			c.remove(elem)
+			ok = false
+		}
+
+		if !ok {
+			e := &cacheEntry{key: key, done: make(chan struct{})}
+			elem = c.order.PushFront(e)
+			c.entries[key] = elem
+			if c.order.Len() > c.size {
+				c.remove(c.order.Back())
+			}
+			c.mu.Unlock()
+
+			return c.load(elem, load)
+		}
+
+		c.order.MoveToFront(elem)
+		c.mu.Unlock()
+
+		e := elem.Value.(*cacheEntry)
+		<-e.done
+		if e.ok {
+			return e.value
+		}
+		// the lookup we waited for failed, retry it ourselves
+	}
+}
+
+func (c *lruCache) load(elem *list.Element, load func() (interface{}, bool)) interface{} {
+	e := elem.Value.(*cacheEntry)
+	value, ok := load()
+
+	c.mu.Lock()
+	e.value, e.ok = value, ok
+	e.expires = time.Now().Add(c.ttl)
+	if current, cached := c.entries[e.key]; !ok && cached && current == elem {
+		c.remove(elem)
+	}
+	c.mu.Unlock()
+
+	close(e.done)
+	return value
+}
+
+// expired reports whether a finished lookup is older than ttl
+func (c *lruCache) expired(e *cacheEntry) bool {
+	select {
+	case <-e.done:
+		return c.ttl > 0 && time.Now().After(e.expires)
+	default:
+		return false
+	}
+}
+
+func (c *lruCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,91 @@
+package webanalyze
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2, time.Hour)
+
+	loads := make(map[string]int)
+	get := func(key string, ok bool) interface{} {
+		return c.get(key, func() (interface{}, bool) {
+			loads[key]++
+			return key + "-value", ok
+		})
+	}
+
+	if v := get("a", true); v != "a-value" {
+		t.Fatalf("unexpected value %v", v)
+	}
+	get("a", true)
+	get("b", true)
+	if loads["a"] != 1 || loads["b"] != 1 {
+		t.Fatalf("cached values loaded again: %v", loads)
+	}
+
+	// a is used more recently than b, which is evicted
+	get("a", true)
+	get("c", true)
+	get("a", true)
+	get("b", true)
+	if loads["a"] != 1 || loads["b"] != 2 || len(c.entries) != 2 {
+		t.Fatalf("unexpected eviction: %v", loads)
+	}
+
+	// failed loads are retried
+	get("d", false)
+	get("d", false)
+	if loads["d"] != 2 {
+		t.Fatalf("failed load was cached: %v", loads)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	c := newLRUCache(10, time.Millisecond)
+
+	loads := 0
+	load := func() (interface{}, bool) {
+		loads++
+		return loads, true
+	}
+
+	c.get("a", load)
+	time.Sleep(5 * time.Millisecond)
+	if v := c.get("a", load); v != 2 {
+		t.Fatalf("expired value returned: %v", v)
+	}
+}
+
+func TestLRUCacheConcurrent(t *testing.T) {
+	c := newLRUCache(10, time.Hour)
+
+	var mu sync.Mutex
+	loads := 0
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.get("a", func() (interface{}, bool) {
+				mu.Lock()
+				loads++
+				mu.Unlock()
+				<-release
+				return "value", true
+			})
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Fatalf("expected a single load, got %v", loads)
+	}
+}
@@ -0,0 +1,69 @@
+package webanalyze
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// TLSInfo describes the connection and the certificate of a https host
+type TLSInfo struct {
+	Version  string    `json:"version"`
+	Cipher   string    `json:"cipher"`
+	Subject  string    `json:"subject"`
+	SANs     []string  `json:"sans,omitempty"`
+	Issuer   string    `json:"issuer"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// newTLSInfo returns the connection details of state and its leaf
+// certificate, or nil for plain http responses
+func newTLSInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	cert := state.PeerCertificates[0]
+	info := &TLSInfo{
+		Version:  tlsVersions[state.Version],
+		Cipher:   tls.CipherSuiteName(state.CipherSuite),
+		Subject:  cert.Subject.String(),
+		Issuer:   cert.Issuer.String(),
+		NotAfter: cert.NotAfter,
+	}
+
+	if info.Version == "" {
+		info.Version = fmt.Sprintf("0x%04x", state.Version)
+	}
+
+	info.SANs = append(info.SANs, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+
+	return info
+}
+
+// certIssuers returns the issuer organization and common name of the leaf
+// certificate, which certIssuer patterns are matched against. Issuers of
+// intermediates are ignored, as they name the root CA and not the CA
+// which issued the certificate of the host.
+func certIssuers(state *tls.ConnectionState) []string {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	issuer := state.PeerCertificates[0].Issuer
+	issuers := append([]string(nil), issuer.Organization...)
+	if issuer.CommonName != "" {
+		issuers = append(issuers, issuer.CommonName)
+	}
+	return unique(issuers)
+}
@@ -0,0 +1,69 @@
+package webanalyze
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProcessCertificate(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer srv.Close()
+
+	// the test server certificate is issued by "Acme Co"
+	wa := newTestAnalyzer(t, `{
+		"Acme CA": {"cats": [1], "certIssuer": "Acme"},
+		"Let's Encrypt": {"cats": [1], "certIssuer": "Let's Encrypt"}
+	}`)
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+	if result.Error != nil {
+		t.Fatalf("process failed: %v", result.Error)
+	}
+
+	if len(result.Matches) != 1 || result.Matches[0].AppName != "Acme CA" {
+		t.Fatalf("expected certificate issuer match, got %v", matchNames(result.Matches))
+	}
+	if e := result.Matches[0].Evidence[0]; e.Source != SourceCertIssuer || e.Match != "Acme" {
+		t.Fatalf("unexpected evidence %+v", e)
+	}
+
+	info := result.TLS
+	if info == nil {
+		t.Fatalf("tls information missing")
+	}
+	if !strings.Contains(info.Issuer, "Acme Co") || !strings.HasPrefix(info.Version, "TLS 1.") || info.Cipher == "" {
+		t.Fatalf("unexpected tls information %+v", info)
+	}
+	if info.NotAfter.IsZero() || !strings.Contains(strings.Join(info.SANs, ","), "example.com") {
+		t.Fatalf("unexpected certificate information %+v", info)
+	}
+
+	result, _ = wa.Process(NewOfflineJob("http://example.com", "", nil))
+	if result.TLS != nil {
+		t.Fatalf("tls information for plain http job")
+	}
+}
+
+func TestCertIssuers(t *testing.T) {
+	// a leaf issued by an intermediate, which is signed by another CA
+	state := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+		{Issuer: pkix.Name{Organization: []string{"Let's Encrypt"}, CommonName: "R3"}},
+		{Issuer: pkix.Name{Organization: []string{"Internet Security Research Group"}, CommonName: "ISRG Root X1"}},
+	}}
+
+	if issuers := certIssuers(state); strings.Join(issuers, ",") != "Let's Encrypt,R3" {
+		t.Fatalf("expected issuers of the leaf certificate, got %v", issuers)
+	}
+
+	if issuers := certIssuers(&tls.ConnectionState{}); issuers != nil {
+		t.Fatalf("expected no issuers without certificates, got %v", issuers)
+	}
+}
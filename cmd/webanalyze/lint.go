@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rverton/webanalyze"
+)
+
+// lint validates a technologies file and returns the exit code, which is
+// non-zero if errors (or, in strict mode, warnings) were found
+func lint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	filename := fs.String("apps", "technologies.json", "technologies definition file")
+	output := fs.String("output", "stdout", "output format (stdout|json)")
+	strict := fs.Bool("strict", false, "fail on warnings (default false)")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		*filename = fs.Arg(0)
+	}
+
+	f, err := os.Open(*filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: can not open apps file %s: %s\n", *filename, err)
+		return 2
+	}
+	defer f.Close()
+
+	issues, err := webanalyze.Lint(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: can not parse apps file %s: %s\n", *filename, err)
+		return 2
+	}
+
+	var errors, warnings int
+	enc := json.NewEncoder(os.Stdout)
+
+	for _, issue := range issues {
+		switch issue.Severity {
+		case webanalyze.LintError:
+			errors++
+		case webanalyze.LintWarning:
+			warnings++
+		}
+
+		if *output == "json" {
+			enc.Encode(issue)
+		} else {
+			fmt.Println(issue)
+		}
+	}
+
+	if *output != "json" {
+		fmt.Fprintf(os.Stderr, "%v: %v errors, %v warnings\n", *filename, errors, warnings)
+	}
+
+	if errors > 0 || (*strict && warnings > 0) {
+		return 1
+	}
+	return 0
+}
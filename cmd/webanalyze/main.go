@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
@@ -11,9 +12,10 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/rverton/webanalyze"
@@ -30,6 +32,18 @@ var (
 	searchSubdomain bool
 	silent          bool
 	redirect        bool
+	fetchScripts    bool
+	resolveDNS      bool
+	fetchRobots     bool
+	minConfidence   int
+	userAgent       string
+	requestTimeout  time.Duration
+	maxBodySize     int64
+	fullMatches     bool
+	verbose         bool
+	sortOrder       string
+	vulnFeeds       string
+	onlyVulnerable  bool
 )
 
 func init() {
@@ -43,6 +57,18 @@ func init() {
 	flag.BoolVar(&searchSubdomain, "search", true, "searches all urls with same base domain (i.e. example.com and sub.example.com)")
 	flag.BoolVar(&silent, "silent", false, "avoid printing header (default false)")
 	flag.BoolVar(&redirect, "redirect", false, "follow http redirects (default false)")
+	flag.StringVar(&userAgent, "useragent", "", "user agent to send with requests")
+	flag.DurationVar(&requestTimeout, "timeout", 8*time.Second, "timeout of a single request")
+	flag.Int64Var(&maxBodySize, "maxbody", 10<<20, "maximum number of bytes read from a response, 0 for no limit")
+	flag.IntVar(&minConfidence, "confidence", 0, "minimum confidence (0-100) of a match to be reported (default 0)")
+	flag.BoolVar(&fullMatches, "full", false, "include the complete app definitions in json output (default false)")
+	flag.StringVar(&sortOrder, "sort", "name", "order of the matches of a host (name|category|confidence)")
+	flag.StringVar(&vulnFeeds, "vulndb", "", "comma separated NVD JSON feeds to match detected versions against")
+	flag.BoolVar(&onlyVulnerable, "vulnerable", false, "only print findings with known vulnerabilities, requires -vulndb (default false)")
+	flag.BoolVar(&verbose, "verbose", false, "print the evidence of each match in stdout mode (default false)")
+	flag.BoolVar(&fetchScripts, "scripts", false, "download external scripts for javascript detection (default false)")
+	flag.BoolVar(&resolveDNS, "dns", true, "match dns records of the scanned domains")
+	flag.BoolVar(&fetchRobots, "robots", false, "fetch robots.txt of each scanned origin for robots detection (default false)")
 }
 
 func main() {
@@ -54,6 +80,10 @@ func main() {
 		outWriter *csv.Writer
 	)
 
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(lint(os.Args[2:]))
+	}
+
 	flag.Parse()
 
 	if !update && host == "" && hosts == "" {
@@ -77,16 +107,18 @@ func main() {
 
 	}
 
-	// lookup technologies.json file
-	techsFilename, err = lookupFolders(techsFilename)
+	// lookup technologies.json file, the embedded snapshot is used as
+	// last resort unless a file was requested explicitly
+	techs, techsDate, err := openTechnologies()
 	if err != nil {
 		log.Fatalf("error: can not open apps file %s: %s", techsFilename, err)
 	}
+	defer techs.Close()
 
 	// add header if output mode is csv
 	if outputMethod == "csv" {
 		outWriter = csv.NewWriter(os.Stdout)
-		outWriter.Write([]string{"Host", "Category", "App", "Version"})
+		outWriter.Write([]string{"Host", "Category", "App", "Version", "CPE", "Website", "Description", "Pricing", "SaaS", "OSS", "Vulnerabilities", "Error Kind", "Error"})
 
 		defer outWriter.Flush()
 
@@ -103,74 +135,103 @@ func main() {
 	}
 	defer file.Close()
 
-	var wg sync.WaitGroup
-	hosts := make(chan string)
+	// abort running scans on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	techsFile, err := os.Open(techsFilename)
-	if err != nil {
-		log.Fatalf("error: can not open apps file %s: %s", techsFilename, err)
+	opts := []webanalyze.Option{
+		webanalyze.WithTimeout(requestTimeout),
+		webanalyze.WithMaxBodySize(maxBodySize),
+		webanalyze.WithFullMatches(fullMatches),
+		webanalyze.WithMatchOrder(sortOrder),
+		webanalyze.WithRobots(fetchRobots),
+	}
+	if userAgent != "" {
+		opts = append(opts, webanalyze.WithUserAgent(userAgent))
+	}
+	if !resolveDNS {
+		opts = append(opts, webanalyze.WithResolver(nil))
 	}
-	defer techsFile.Close()
 
-	if wa, err = webanalyze.NewWebAnalyzer(techsFile, nil); err != nil {
-		log.Fatalf("initialization failed: %v", err)
+	if vulnFeeds != "" {
+		db, err := loadVulnDB(vulnFeeds)
+		if err != nil {
+			log.Fatalf("error: can not load vulnerability feeds: %v", err)
+		}
+		opts = append(opts, webanalyze.WithVulnDB(db))
+	} else if onlyVulnerable {
+		log.Fatalf("error: -vulnerable requires -vulndb")
 	}
 
-	if !silent {
-		printHeader()
+	if wa, err = webanalyze.NewWebAnalyzerWithOptions(techs, opts...); err != nil {
+		log.Fatalf("initialization failed: %v", err)
 	}
 
-	appsInfo, err := os.Stat(techsFilename)
-	if err != nil {
-		log.Fatalf("error: cant open %v: %v", techsFilename, err)
+	if !silent {
+		printHeader(wa, techsDate)
 	}
 
-	if appsInfo.ModTime().Before(time.Now().Add(24 * time.Hour * 7 * -1)) {
+	if techsDate.Before(time.Now().Add(24 * time.Hour * 7 * -1)) {
 		log.Printf("warning: %v is older than a week", techsFilename)
 	}
 
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
+	jobs := make(chan *webanalyze.Job)
+	results := webanalyze.NewScanner(wa, workers).Run(ctx, jobs)
 
-			for host := range hosts {
-				job := webanalyze.NewOnlineJob(host, "", nil, crawlCount, searchSubdomain, redirect)
-				result, links := wa.Process(job)
-
-				if searchSubdomain {
-					for _, v := range links {
-						crawlJob := webanalyze.NewOnlineJob(v, "", nil, 0, false, redirect)
-						result, _ := wa.Process(crawlJob)
-						output(result, wa, outWriter)
-					}
-				}
-
-				output(result, wa, outWriter)
+	// read hosts from file
+	go func() {
+		defer close(jobs)
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			job := webanalyze.NewOnlineJob(scanner.Text(), "", nil, crawlCount, searchSubdomain, redirect)
+			job.FetchScripts = fetchScripts
+
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
 			}
+		}
+	}()
 
-			wg.Done()
-		}()
-	}
-
-	// read hosts from file
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		hosts <- scanner.Text()
+	for result := range results {
+		output(result, wa, outWriter)
 	}
-
-	close(hosts)
-	wg.Wait()
 }
 
 func output(result webanalyze.Result, wa *webanalyze.WebAnalyzer, outWriter *csv.Writer) {
+	errKind, errMsg := "", ""
 	if result.Error != nil {
-		fmt.Fprintf(os.Stderr, "%v error: %v\n", result.Host, result.Error)
-		return
+		errKind, errMsg = errorKind(result.Error), result.Error.Error()
+	}
+
+	result.Matches = filterConfidence(result.Matches)
+
+	if onlyVulnerable {
+		result.Matches = filterVulnerable(result.Matches)
+		if len(result.Matches) == 0 && result.Error == nil {
+			return
+		}
 	}
 
 	switch outputMethod {
 	case "stdout":
-		fmt.Printf("%v (%.1fs):\n", result.Host, result.Duration.Seconds())
+		if result.Error != nil {
+			fmt.Fprintf(os.Stderr, "%v error (%v): %v\n", result.Host, errKind, errMsg)
+			if len(result.Matches) == 0 {
+				return
+			}
+		}
+
+		truncated := ""
+		if result.Truncated {
+			truncated = ", truncated"
+		}
+		fmt.Printf("%v (%.1fs%v):\n", result.Host, result.Duration.Seconds(), truncated)
+		if verbose && result.TLS != nil {
+			printTLS(result.TLS)
+		}
 		for _, a := range result.Matches {
 
 			var categories []string
@@ -180,12 +241,28 @@ func output(result webanalyze.Result, wa *webanalyze.WebAnalyzer, outWriter *csv
 			}
 
 			fmt.Printf("    %v, %v (%v)\n", a.AppName, a.Version, strings.Join(categories, ", "))
+
+			if len(a.Vulnerabilities) > 0 {
+				fmt.Printf("        vulnerable: %v\n", formatVulnerabilities(a.Vulnerabilities))
+			}
+
+			if verbose {
+				printMetadata(a)
+				printEvidence(a.Evidence)
+				if len(a.ImpliedBy) > 0 {
+					fmt.Printf("        implied by %v\n", strings.Join(a.ImpliedBy, ", "))
+				}
+			}
 		}
 		if len(result.Matches) <= 0 {
 			fmt.Printf("    <no results>\n")
 		}
 
 	case "csv":
+		// errored hosts without matches still get a row
+		if result.Error != nil && len(result.Matches) == 0 {
+			outWriter.Write([]string{result.Host, "", "", "", "", "", "", "", "", "", "", errKind, errMsg})
+		}
 		for _, m := range result.Matches {
 			outWriter.Write(
 				[]string{
@@ -193,6 +270,15 @@ func output(result webanalyze.Result, wa *webanalyze.WebAnalyzer, outWriter *csv
 					strings.Join(m.CatNames, ","),
 					m.AppName,
 					m.Version,
+					m.VersionedCPE(),
+					m.Website,
+					m.Description,
+					strings.Join(m.Pricing, ","),
+					strconv.FormatBool(m.SaaS),
+					strconv.FormatBool(m.OSS),
+					formatVulnerabilities(m.Vulnerabilities),
+					errKind,
+					errMsg,
 				},
 			)
 		}
@@ -200,11 +286,15 @@ func output(result webanalyze.Result, wa *webanalyze.WebAnalyzer, outWriter *csv
 	case "json":
 
 		output := struct {
-			Hostname string             `json:"hostname"`
-			Matches  []webanalyze.Match `json:"matches"`
+			Hostname string              `json:"hostname"`
+			Matches  []webanalyze.Match  `json:"matches"`
+			TLS      *webanalyze.TLSInfo `json:"tls,omitempty"`
+			Error    error               `json:"error,omitempty"`
 		}{
 			result.Host,
 			result.Matches,
+			result.TLS,
+			result.Error,
 		}
 
 		b, err := json.Marshal(output)
@@ -217,13 +307,135 @@ func output(result webanalyze.Result, wa *webanalyze.WebAnalyzer, outWriter *csv
 	}
 }
 
-func printHeader() {
+// filterVulnerable drops all matches without known vulnerabilities
+func filterVulnerable(matches []webanalyze.Match) []webanalyze.Match {
+	filtered := make([]webanalyze.Match, 0, len(matches))
+	for _, m := range matches {
+		if len(m.Vulnerabilities) > 0 {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+// formatVulnerabilities lists CVE ids with their CVSS scores
+func formatVulnerabilities(vulns []webanalyze.Vulnerability) string {
+	var list []string
+	for _, v := range vulns {
+		if v.Score > 0 {
+			list = append(list, fmt.Sprintf("%v (%.1f)", v.ID, v.Score))
+		} else {
+			list = append(list, v.ID)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// loadVulnDB loads a comma separated list of NVD feed files
+func loadVulnDB(filenames string) (*webanalyze.VulnDB, error) {
+	db := webanalyze.NewVulnDB()
+	for _, filename := range strings.Split(filenames, ",") {
+		f, err := os.Open(strings.TrimSpace(filename))
+		if err != nil {
+			return nil, err
+		}
+		err = db.Load(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+	}
+	return db, nil
+}
+
+// printTLS prints the connection and certificate details of a host
+func printTLS(info *webanalyze.TLSInfo) {
+	fmt.Printf("    tls: %v, %v\n", info.Version, info.Cipher)
+	fmt.Printf("    certificate: %v, issued by %v, expires %v\n", info.Subject, info.Issuer, info.NotAfter.Format("2006-01-02"))
+	if len(info.SANs) > 0 {
+		fmt.Printf("    alternative names: %v\n", strings.Join(info.SANs, ", "))
+	}
+}
+
+// printMetadata prints the upstream metadata of a match
+func printMetadata(m webanalyze.Match) {
+	if m.Description != "" {
+		fmt.Printf("        %v\n", m.Description)
+	}
+	if cpe := m.VersionedCPE(); cpe != "" {
+		fmt.Printf("        cpe: %v\n", cpe)
+	}
+
+	var flags []string
+	if m.SaaS {
+		flags = append(flags, "saas")
+	}
+	if m.OSS {
+		flags = append(flags, "oss")
+	}
+	flags = append(flags, m.Pricing...)
+	if len(flags) > 0 {
+		fmt.Printf("        %v\n", strings.Join(flags, ", "))
+	}
+}
+
+// printEvidence prints where and by which pattern a match was detected
+func printEvidence(evidence []webanalyze.Evidence) {
+	for _, e := range evidence {
+		source := e.Source
+		if e.Key != "" {
+			source += " " + e.Key
+		} else if e.Source == webanalyze.SourceHTML || e.Source == webanalyze.SourceScript {
+			source += fmt.Sprintf(" @%v", e.Offset)
+		}
+
+		fmt.Printf("        %v: %q matched %q", source, e.Pattern, e.Match)
+		if e.Version != "" {
+			fmt.Printf(", version %v", e.Version)
+		}
+		fmt.Printf("\n")
+	}
+}
+
+// errorKind returns the kind of a result error
+func errorKind(err error) string {
+	var se *webanalyze.ScanError
+	if errors.As(err, &se) {
+		return se.Kind
+	}
+	return webanalyze.ErrorKindUnknown
+}
+
+// filterConfidence drops all matches below the configured confidence
+func filterConfidence(matches []webanalyze.Match) []webanalyze.Match {
+	if minConfidence <= 0 {
+		return matches
+	}
+
+	filtered := make([]webanalyze.Match, 0, len(matches))
+	for _, m := range matches {
+		if m.Confidence >= minConfidence {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func printHeader(wa *webanalyze.WebAnalyzer, techsDate time.Time) {
 	printOption("webanalyze", "v"+webanalyze.VERSION)
 	printOption("workers", workers)
-	printOption("technologies", techsFilename)
+	printOption("technologies", fmt.Sprintf("%v (%v)", techsFilename, techsDate.Format("2006-01-02")))
 	printOption("crawl count", crawlCount)
 	printOption("search subdomains", searchSubdomain)
 	printOption("follow redirects", redirect)
+	printOption("fetch scripts", fetchScripts)
+	printOption("resolve dns", resolveDNS)
+	printOption("fetch robots.txt", fetchRobots)
+	printOption("min confidence", minConfidence)
+	printOption("degraded patterns", len(wa.DegradedPatterns()))
+	if vulnFeeds != "" {
+		printOption("vulnerability db", vulnFeeds)
+	}
 	fmt.Printf("\n")
 }
 
@@ -231,6 +443,46 @@ func printOption(name string, value interface{}) {
 	fmt.Fprintf(os.Stderr, " :: %-17s : %v\n", name, value)
 }
 
+// openTechnologies opens the technologies file and returns its date. If
+// the default file can not be found, the embedded snapshot is used.
+func openTechnologies() (io.ReadCloser, time.Time, error) {
+	explicit := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "apps" {
+			explicit = true
+		}
+	})
+
+	path, err := lookupFolders(techsFilename)
+	if err != nil {
+		if explicit {
+			return nil, time.Time{}, err
+		}
+
+		r, date, err := webanalyze.EmbeddedTechnologies()
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+
+		techsFilename = "embedded snapshot"
+		return ioutil.NopCloser(r), date, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, time.Time{}, err
+	}
+
+	techsFilename = path
+	return f, info.ModTime(), nil
+}
+
 func lookupFolders(filename string) (string, error) {
 	if filepath.IsAbs(filename) {
 		return filename, nil
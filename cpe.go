@@ -0,0 +1,60 @@
+package webanalyze
+
+import "strings"
+
+// cpeVersionField is the index of the version in a CPE 2.3 formatted string,
+// e.g. cpe:2.3:a:wordpress:wordpress:6.1:*:*:*:*:*:*:*
+const cpeVersionField = 5
+
+// VersionedCPE returns the CPE of the detected app with the detected
+// version filled in. Without a version the CPE is returned as defined.
+func (m Match) VersionedCPE() string {
+	return cpeWithVersion(m.CPE, m.Version)
+}
+
+// cpeWithVersion replaces the version field of a CPE 2.3 formatted string
+func cpeWithVersion(cpe, version string) string {
+	if cpe == "" || version == "" {
+		return cpe
+	}
+
+	fields := splitCPE(cpe)
+	if len(fields) <= cpeVersionField || fields[0] != "cpe" || fields[1] != "2.3" {
+		return cpe
+	}
+
+	fields[cpeVersionField] = escapeCPE(version)
+	return strings.Join(fields, ":")
+}
+
+// splitCPE splits a CPE 2.3 formatted string into its fields, keeping
+// escaped colons
+func splitCPE(cpe string) []string {
+	var fields []string
+	start := 0
+	for i := 0; i < len(cpe); i++ {
+		switch cpe[i] {
+		case '\\':
+			i++
+		case ':':
+			fields = append(fields, cpe[start:i])
+			start = i + 1
+		}
+	}
+	return append(fields, cpe[start:])
+}
+
+// escapeCPE escapes all characters of a value which are not allowed
+// unquoted in a CPE 2.3 formatted string
+func escapeCPE(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,80 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCPEWithVersion(t *testing.T) {
+	cases := []struct {
+		cpe, version, expected string
+	}{
+		{"cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*", "6.1.1", "cpe:2.3:a:wordpress:wordpress:6.1.1:*:*:*:*:*:*:*"},
+		{"cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*", "", "cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*"},
+		{"cpe:2.3:a:vendor\\:x:product:*:*:*:*:*:*:*:*", "1.0 RC", "cpe:2.3:a:vendor\\:x:product:1.0\\ rc:*:*:*:*:*:*:*"},
+		{"", "1.0", ""},
+		{"cpe:/a:apache:http_server", "2.4", "cpe:/a:apache:http_server"},
+	}
+
+	for _, c := range cases {
+		if cpe := cpeWithVersion(c.cpe, c.version); cpe != c.expected {
+			t.Fatalf("%v with %v: expected %v, got %v", c.cpe, c.version, c.expected, cpe)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	technologies := `{"categories": {"1": {"name": "CMS"}}, "technologies": {
+		"WordPress": {
+			"cats": [1],
+			"meta": {"generator": "^WordPress ([\\d.]+)\\;version:\\1"},
+			"cpe": "cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*",
+			"icon": "WordPress.svg",
+			"description": "WordPress is a content management system.",
+			"pricing": ["low", "freemium"],
+			"oss": true
+		}
+	}}`
+
+	var defs AppsDefinition
+	if err := json.Unmarshal([]byte(technologies), &defs); err != nil {
+		t.Fatalf("could not decode technologies: %v", err)
+	}
+
+	// metadata survives writing the technologies file, see DownloadFile
+	data, err := json.Marshal(defs)
+	if err != nil {
+		t.Fatalf("could not encode technologies: %v", err)
+	}
+
+	wa, err := NewWebAnalyzer(strings.NewReader(string(data)), nil)
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOfflineJob("http://example.com", `<meta name="generator" content="WordPress 6.1">`, nil))
+	if len(result.Matches) != 1 {
+		t.Fatalf("expected 1 match, got %v", len(result.Matches))
+	}
+
+	b, err := json.Marshal(result.Matches[0])
+	if err != nil {
+		t.Fatalf("could not marshal match: %v", err)
+	}
+
+	for _, expected := range []string{
+		`"cpe":"cpe:2.3:a:wordpress:wordpress:6.1:*:*:*:*:*:*:*"`,
+		`"icon":"WordPress.svg"`,
+		`"description":"WordPress is a content management system."`,
+		`"pricing":["low","freemium"]`,
+		`"oss":true`,
+	} {
+		if !strings.Contains(string(b), expected) {
+			t.Fatalf("%v missing in %s", expected, b)
+		}
+	}
+	if strings.Contains(string(b), `"saas"`) {
+		t.Fatalf("unset saas flag in %s", b)
+	}
+}
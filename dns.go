@@ -0,0 +1,177 @@
+package webanalyze
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bobesa/go-domain-util/domainutil"
+)
+
+// Resolver looks up the records of a domain which dns fingerprints are
+// matched against. recordType is one of TXT, MX, NS, CNAME or SOA. Missing
+// records are no error, errors are treated as temporary and the lookup is
+// repeated for the next job of the domain.
+type Resolver interface {
+	LookupRecords(ctx context.Context, domain, recordType string) ([]string, error)
+}
+
+// NetResolver resolves records using a net.Resolver. SOA records are not
+// supported by the net package and never returned.
+type NetResolver struct {
+	Resolver *net.Resolver
+}
+
+// LookupRecords implements Resolver
+func (r *NetResolver) LookupRecords(ctx context.Context, domain, recordType string) ([]string, error) {
+	records, err := r.lookup(ctx, domain, recordType)
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, nil
+	}
+	return records, err
+}
+
+func (r *NetResolver) lookup(ctx context.Context, domain, recordType string) ([]string, error) {
+	var records []string
+
+	switch recordType {
+	case "TXT":
+		return r.Resolver.LookupTXT(ctx, domain)
+	case "MX":
+		mxs, err := r.Resolver.LookupMX(ctx, domain)
+		for _, mx := range mxs {
+			records = append(records, strings.TrimSuffix(mx.Host, "."))
+		}
+		return records, err
+	case "NS":
+		nss, err := r.Resolver.LookupNS(ctx, domain)
+		for _, ns := range nss {
+			records = append(records, strings.TrimSuffix(ns.Host, "."))
+		}
+		return records, err
+	case "CNAME":
+		cname, err := r.Resolver.LookupCNAME(ctx, domain)
+		if err != nil || strings.TrimSuffix(cname, ".") == domain {
+			return nil, err
+		}
+		return []string{strings.TrimSuffix(cname, ".")}, nil
+	}
+
+	return nil, nil
+}
+
+// dnsCacheSize and dnsCacheTTL bound the records kept of scanned hosts
+const (
+	dnsCacheSize = 10000
+	dnsCacheTTL  = 10 * time.Minute
+)
+
+// dnsRecords returns the records of the host of rawURL. CNAME records
+// belong to the host itself, all other record types to its registered
+// domain. Records of a domain are resolved once and cached, unless the
+// lookup failed.
+func (wa *WebAnalyzer) dnsRecords(ctx context.Context, rawURL string) map[string][]string {
+	if wa.resolver == nil || len(wa.dnsTypes) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	domain := dnsDomain(u.Hostname())
+	if domain == "" {
+		return nil
+	}
+
+	var domainTypes, hostTypes []string
+	for _, recordType := range wa.dnsTypes {
+		if recordType == "CNAME" {
+			hostTypes = append(hostTypes, recordType)
+		} else {
+			domainTypes = append(domainTypes, recordType)
+		}
+	}
+
+	records := wa.resolveRecords(ctx, domain, domainTypes)
+	for recordType, list := range wa.resolveRecords(ctx, strings.ToLower(u.Hostname()), hostTypes) {
+		records[recordType] = list
+	}
+	return records
+}
+
+// resolveRecords returns the cached records of the given types for name
+func (wa *WebAnalyzer) resolveRecords(ctx context.Context, name string, recordTypes []string) map[string][]string {
+	if len(recordTypes) == 0 {
+		return make(map[string][]string)
+	}
+
+	key := name + " " + strings.Join(recordTypes, ",")
+	records := wa.dns.get(key, func() (interface{}, bool) {
+		records := make(map[string][]string)
+		for _, recordType := range recordTypes {
+			list, err := wa.resolver.LookupRecords(ctx, name, recordType)
+			if err != nil {
+				// keep what was resolved, but retry on the next job
+				return records, false
+			}
+			records[recordType] = list
+		}
+		return records, ctx.Err() == nil
+	})
+
+	// copy, as the records of the host are merged into the result
+	res := make(map[string][]string)
+	for recordType, list := range records.(map[string][]string) {
+		res[recordType] = list
+	}
+	return res
+}
+
+// dnsDomain returns the registered domain of a host, dns fingerprints do
+// not apply to ip addresses
+func dnsDomain(host string) string {
+	if host == "" || net.ParseIP(host) != nil {
+		return ""
+	}
+	if domain := domainutil.Domain(host); domain != "" {
+		return domain
+	}
+	return host
+}
+
+// dnsRecordTypes returns the record types used by the dns fingerprints of
+// all apps
+func dnsRecordTypes(apps map[string]App) []string {
+	seen := make(map[string]bool)
+	for _, app := range apps {
+		for _, re := range app.DNSRegex {
+			seen[re.Name] = true
+		}
+	}
+
+	types := make([]string, 0, len(seen))
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// findInDNS matches the dns regexes of an app against the records of the
+// domain of a page
+func (app *App) findInDNS(records map[string][]string) (evidence []Evidence) {
+	for _, dre := range app.DNSRegex {
+		for _, record := range records[dre.Name] {
+			evidence = append(evidence, findMatches(record, []AppRegexp{dre}, SourceDNS, dre.Name)...)
+		}
+	}
+	return evidence
+}
@@ -0,0 +1,170 @@
+package webanalyze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubResolver serves fixed records by name and type and counts the
+// lookups of each name. The first lookups of fail names return an error.
+type stubResolver struct {
+	mu      sync.Mutex
+	records map[string]map[string][]string
+	fail    map[string]int
+	lookups map[string]int
+}
+
+func (r *stubResolver) LookupRecords(ctx context.Context, domain, recordType string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lookups[domain]++
+	if r.fail[domain] > 0 {
+		r.fail[domain]--
+		return nil, errors.New("temporary failure")
+	}
+	return r.records[domain][recordType], nil
+}
+
+// newDNSTestAnalyzer returns an analyzer which sends the requests of all
+// hosts to a test server and resolves records with resolver
+func newDNSTestAnalyzer(t *testing.T, resolver Resolver) (*WebAnalyzer, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html></html>")
+	}))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+		},
+	}}
+
+	defs := `{"categories": {}, "technologies": {
+		"Google Workspace": {"dns": {"MX": ["aspmx\\.l\\.google\\.com", "googlemail\\.com"], "txt": "_spf\\.google\\.com\\;confidence:50"}},
+		"Mailchimp": {"dns": {"TXT": "mandrill"}},
+		"Fastly": {"dns": {"CNAME": "\\.fastly\\.net"}}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithHTTPClient(client), WithResolver(resolver))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+	return wa, srv.Close
+}
+
+func TestProcessDNS(t *testing.T) {
+	resolver := &stubResolver{
+		records: map[string]map[string][]string{
+			"example.com": {
+				"TXT": {"v=spf1 include:_spf.google.com ~all", "google-site-verification=abc"},
+				"MX":  {"aspmx.l.google.com"},
+			},
+			"www.example.com": {"CNAME": {"example.map.fastly.net"}},
+		},
+		lookups: make(map[string]int),
+	}
+
+	wa, done := newDNSTestAnalyzer(t, resolver)
+	defer done()
+
+	if strings.Join(wa.dnsTypes, ",") != "CNAME,MX,TXT" {
+		t.Fatalf("unexpected record types %v", wa.dnsTypes)
+	}
+
+	expected := map[string]string{
+		"http://www.example.com":       "Fastly,Google Workspace",
+		"http://shop.example.com/cart": "Google Workspace",
+	}
+	for _, host := range []string{"http://www.example.com", "http://shop.example.com/cart"} {
+		result, _ := wa.Process(NewOnlineJob(host, "", nil, 0, false, false))
+		if result.Error != nil {
+			t.Fatalf("%v: process failed: %v", host, result.Error)
+		}
+
+		var names []string
+		for _, m := range result.Matches {
+			names = append(names, m.AppName)
+		}
+		if strings.Join(names, ",") != expected[host] {
+			t.Fatalf("%v: expected %v, got %v", host, expected[host], names)
+		}
+
+		m := result.Matches[len(result.Matches)-1]
+		if m.Confidence != 100 || len(m.Evidence) != 2 {
+			t.Fatalf("%v: unexpected match %+v", host, m)
+		}
+		if e := m.Evidence[1]; e.Source != SourceDNS || e.Key != "TXT" || e.Match != "_spf.google.com" {
+			t.Fatalf("%v: unexpected evidence %+v", host, e)
+		}
+	}
+
+	// both hosts share a domain, its record types are resolved once, cname
+	// records are resolved per host
+	lookups := map[string]int{"example.com": 2, "www.example.com": 1, "shop.example.com": 1}
+	if !reflect.DeepEqual(resolver.lookups, lookups) {
+		t.Fatalf("unexpected lookups %v", resolver.lookups)
+	}
+
+	// offline jobs and ip addresses are not resolved
+	wa.Process(NewOfflineJob("http://other.com", "", nil))
+	wa.Process(NewOnlineJob("http://127.0.0.1", "", nil, 0, false, false))
+	if !reflect.DeepEqual(resolver.lookups, lookups) {
+		t.Fatalf("unexpected lookups %v", resolver.lookups)
+	}
+}
+
+func TestProcessDNSFailure(t *testing.T) {
+	resolver := &stubResolver{
+		records: map[string]map[string][]string{
+			"example.com": {"MX": {"aspmx.l.google.com"}},
+		},
+		fail:    map[string]int{"example.com": 1},
+		lookups: make(map[string]int),
+	}
+
+	wa, done := newDNSTestAnalyzer(t, resolver)
+	defer done()
+
+	result, _ := wa.Process(NewOnlineJob("http://example.com", "", nil, 0, false, false))
+	if len(result.Matches) != 0 {
+		t.Fatalf("expected no matches of failed lookup, got %v", matchNames(result.Matches))
+	}
+
+	// the failed lookup is not cached
+	result, _ = wa.Process(NewOnlineJob("http://example.com", "", nil, 0, false, false))
+	if len(result.Matches) != 1 || result.Matches[0].AppName != "Google Workspace" {
+		t.Fatalf("expected dns match after retry, got %v", matchNames(result.Matches))
+	}
+
+	// a canceled job does not cache records either
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	wa.dnsRecords(ctx, "http://other.com")
+	if _, ok := wa.dns.entries["other.com MX,TXT"]; ok || resolver.lookups["other.com"] == 0 {
+		t.Fatalf("records of canceled job cached")
+	}
+}
+
+func TestDNSDomain(t *testing.T) {
+	cases := map[string]string{
+		"www.example.com":   "example.com",
+		"example.co.uk":     "example.co.uk",
+		"a.b.example.co.uk": "example.co.uk",
+		"127.0.0.1":         "",
+		"::1":               "",
+		"":                  "",
+	}
+
+	for host, expected := range cases {
+		if domain := dnsDomain(host); domain != expected {
+			t.Fatalf("%v: expected %q, got %q", host, expected, domain)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+)
+
+// DOMSelector describes what to check on the elements matched by a css
+// selector of the dom field. Without any of the checks set, the existence
+// of a matching element is enough.
+type DOMSelector struct {
+	Exists     *string           `json:"exists,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Properties map[string]string `json:"properties,omitempty"`
+	Text       *string           `json:"text,omitempty"`
+}
+
+// DOMSelectors maps css selectors to the checks performed on matching elements
+type DOMSelectors map[string]DOMSelector
+
+// DOMMatcher is the compiled form of a single DOMSelector
+type DOMMatcher struct {
+	Selector   string
+	Matcher    goquery.Matcher
+	Exists     bool
+	Text       []AppRegexp
+	Attributes []AppRegexp
+	Properties []AppRegexp
+}
+
+// UnmarshalJSON handles the different shapes of the dom field, which can be
+// a single selector, a list of selectors or an object of selectors
+func (d *DOMSelectors) UnmarshalJSON(data []byte) error {
+	var s string
+	var sa []string
+	var m map[string]DOMSelector
+
+	exists := func(selectors ...string) DOMSelectors {
+		res := make(DOMSelectors)
+		for _, sel := range selectors {
+			empty := ""
+			res[sel] = DOMSelector{Exists: &empty}
+		}
+		return res
+	}
+
+	if err := json.Unmarshal(data, &s); err == nil {
+		*d = exists(s)
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &sa); err == nil {
+		*d = exists(sa...)
+		return nil
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*d = m
+	return nil
+}
+
+// compileDOM compiles the selectors and regexes of the dom field. Invalid
+// selectors are skipped.
+func (pc *patternCompiler) compileDOM(d DOMSelectors) []DOMMatcher {
+	var list []DOMMatcher
+
+	selectors := make([]string, 0, len(d))
+	for selector := range d {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+
+	for _, selector := range selectors {
+		checks := d[selector]
+		compiled, err := cascadia.Compile(selector)
+		if err != nil {
+			continue
+		}
+
+		m := DOMMatcher{
+			Selector:   selector,
+			Matcher:    compiled,
+			Exists:     checks.Exists != nil,
+			Attributes: pc.compileNamedRegexes("dom", checks.Attributes),
+			Properties: pc.compileNamedRegexes("dom", checks.Properties),
+		}
+
+		if checks.Text != nil {
+			m.Text = pc.compileNamedRegexes("dom", map[string]string{"": *checks.Text})
+		}
+
+		// a selector without any checks only requires existence
+		if checks.Attributes == nil && checks.Properties == nil && checks.Text == nil {
+			m.Exists = true
+		}
+
+		list = append(list, m)
+	}
+
+	return list
+}
+
+// findInDOM evaluates the dom matchers of an app against a parsed document.
+// As there is no javascript runtime, properties are approximated by the
+// attribute of the same name.
+func (app *App) findInDOM(doc *goquery.Document) (evidence []Evidence) {
+	for _, dm := range app.DOMMatchers {
+		sel := doc.FindMatcher(dm.Matcher)
+		if sel.Length() == 0 {
+			continue
+		}
+
+		if dm.Exists {
+			evidence = append(evidence, Evidence{
+				Source:     SourceDOM,
+				Key:        dm.Selector,
+				Match:      dm.Selector,
+				Confidence: defaultConfidence,
+				submatches: [][]string{{dm.Selector}},
+			})
+		}
+
+		sel.Each(func(i int, s *goquery.Selection) {
+			if len(dm.Text) > 0 {
+				evidence = append(evidence, findMatches(strings.TrimSpace(s.Text()), dm.Text, SourceDOM, dm.Selector)...)
+			}
+
+			for _, attrs := range [][]AppRegexp{dm.Attributes, dm.Properties} {
+				for _, re := range attrs {
+					value, ok := s.Attr(re.Name)
+					if !ok {
+						continue
+					}
+					key := dm.Selector + "[" + re.Name + "]"
+					evidence = append(evidence, findMatches(value, []AppRegexp{re}, SourceDOM, key)...)
+				}
+			}
+		})
+	}
+
+	return evidence
+}
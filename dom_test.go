@@ -0,0 +1,63 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestDOMSelectorsUnmarshal(t *testing.T) {
+	cases := map[string]int{
+		`"#app"`:           1,
+		`["#app", ".foo"]`: 2,
+		`{"#app": {"exists": ""}, "link": {"attributes": {"href": "foo"}}}`: 2,
+	}
+
+	for data, count := range cases {
+		var d DOMSelectors
+		if err := json.Unmarshal([]byte(data), &d); err != nil {
+			t.Fatalf("unmarshal %v failed: %v", data, err)
+		}
+		if len(d) != count {
+			t.Fatalf("unmarshal %v: expected %v selectors, got %v", data, count, len(d))
+		}
+	}
+}
+
+func TestFindInDOM(t *testing.T) {
+	page := `<html><head>
+	<link rel="stylesheet" href="/wp-content/themes/foo/style.css?ver=5.8.1">
+	</head><body><div id="app" data-v-app>Powered by Foo</div></body></html>`
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Invalid testing document")
+	}
+
+	var d DOMSelectors
+	data := `{
+		"link[href*='/wp-content/']": {"attributes": {"href": "ver=([\\d.]+)\\;version:\\1"}},
+		"#app": {"text": "Powered by Foo"},
+		"[invalid": {"exists": ""}
+	}`
+	if err := json.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatalf("unmarshal failed: %v", err)
+	}
+
+	pc := &patternCompiler{app: "Test"}
+	app := App{DOMMatchers: pc.compileDOM(d)}
+	if len(app.DOMMatchers) != 2 {
+		t.Fatalf("invalid selector was not skipped")
+	}
+
+	var m Match
+	m.update(app.findInDOM(doc)...)
+	if len(m.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", len(m.Matches))
+	}
+	if m.Version != "5.8.1" {
+		t.Fatalf("expected version 5.8.1, got %v", m.Version)
+	}
+}
@@ -0,0 +1,38 @@
+package webanalyze
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"io"
+	"net/http"
+	"time"
+)
+
+//go:generate go run gen_technologies.go
+
+// embeddedTechnologies is a gzip compressed snapshot of technologies.json.
+// The modification time of the gzip header marks the snapshot date.
+//
+//go:embed technologies.json.gz
+var embeddedTechnologies []byte
+
+// EmbeddedTechnologies returns a reader for the technologies snapshot
+// compiled into the binary and the date of the snapshot
+func EmbeddedTechnologies() (io.Reader, time.Time, error) {
+	r, err := gzip.NewReader(bytes.NewReader(embeddedTechnologies))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return r, r.ModTime, nil
+}
+
+// NewWebAnalyzerDefault initializes webanalyzer with the embedded
+// technologies snapshot
+func NewWebAnalyzerDefault(client *http.Client) (*WebAnalyzer, error) {
+	r, _, err := EmbeddedTechnologies()
+	if err != nil {
+		return nil, err
+	}
+	return NewWebAnalyzer(r, client)
+}
@@ -0,0 +1,20 @@
+package webanalyze
+
+import (
+	"testing"
+)
+
+func TestNewWebAnalyzerDefault(t *testing.T) {
+	wa, err := NewWebAnalyzerDefault(nil)
+	if err != nil {
+		t.Fatalf("could not load embedded technologies: %v", err)
+	}
+
+	if len(wa.appDefs.Apps) == 0 || len(wa.appDefs.Cats) == 0 {
+		t.Fatalf("embedded technologies are empty")
+	}
+
+	if _, date, _ := EmbeddedTechnologies(); date.IsZero() {
+		t.Fatalf("embedded technologies have no snapshot date")
+	}
+}
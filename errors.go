@@ -0,0 +1,121 @@
+package webanalyze
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Kinds of errors a Result can carry, see ScanError
+const (
+	ErrorKindDNS               = "dns"
+	ErrorKindConnectionRefused = "connection_refused"
+	ErrorKindConnection        = "connection"
+	ErrorKindTLS               = "tls"
+	ErrorKindTimeout           = "timeout"
+	ErrorKindHTTPStatus        = "http_status"
+	ErrorKindParse             = "parse"
+	ErrorKindCanceled          = "canceled"
+	ErrorKindUnknown           = "unknown"
+)
+
+// Sentinel errors matching the Result error of the corresponding kind
+// using errors.Is
+var (
+	ErrDNS               = errors.New("dns lookup failed")
+	ErrConnectionRefused = errors.New("connection refused")
+	ErrConnection        = errors.New("connection failed")
+	ErrTLS               = errors.New("tls handshake failed")
+	ErrTimeout           = errors.New("timeout")
+	ErrHTTPStatus        = errors.New("http error status")
+	ErrParse             = errors.New("parse failed")
+	ErrCanceled          = errors.New("scan canceled")
+)
+
+var kindSentinels = map[string]error{
+	ErrorKindDNS:               ErrDNS,
+	ErrorKindConnectionRefused: ErrConnectionRefused,
+	ErrorKindConnection:        ErrConnection,
+	ErrorKindTLS:               ErrTLS,
+	ErrorKindTimeout:           ErrTimeout,
+	ErrorKindHTTPStatus:        ErrHTTPStatus,
+	ErrorKindParse:             ErrParse,
+	ErrorKindCanceled:          ErrCanceled,
+}
+
+// ScanError is the error type of Result.Error. It matches the sentinel
+// of its kind as well as the underlying error using errors.Is and is
+// serialised as kind and message.
+type ScanError struct {
+	Kind string
+	Err  error
+}
+
+func (e *ScanError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Is implements errors.Is for the sentinel of the error kind
+func (e *ScanError) Is(target error) bool {
+	sentinel, ok := kindSentinels[e.Kind]
+	return ok && target == sentinel
+}
+
+// MarshalJSON implements json.Marshaler
+func (e *ScanError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind    string `json:"kind"`
+		Message string `json:"message"`
+	}{e.Kind, e.Error()})
+}
+
+// classifyError wraps err into a ScanError of the matching kind
+func classifyError(err error) *ScanError {
+	var se *ScanError
+	if errors.As(err, &se) {
+		return se
+	}
+
+	return &ScanError{Kind: errorKind(err), Err: err}
+}
+
+func errorKind(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var opErr *net.OpError
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorKindCanceled
+	case errors.As(err, &dnsErr):
+		return ErrorKindDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorKindConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorKindTimeout
+	case errors.As(err, &recordErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &invalidErr),
+		errors.As(err, &hostnameErr),
+		strings.Contains(err.Error(), "tls: "):
+		return ErrorKindTLS
+	case errors.As(err, &opErr):
+		return ErrorKindConnection
+	}
+
+	return ErrorKindUnknown
+}
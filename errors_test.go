@@ -0,0 +1,88 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	timeoutErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	cases := []struct {
+		err      error
+		kind     string
+		sentinel error
+	}{
+		{&net.DNSError{Err: "no such host", Name: "invalid.example", IsNotFound: true}, ErrorKindDNS, ErrDNS},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, ErrorKindConnectionRefused, ErrConnectionRefused},
+		{timeoutErr, ErrorKindTimeout, ErrTimeout},
+		{errors.New("remote error: tls: handshake failure"), ErrorKindTLS, ErrTLS},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, ErrorKindConnection, ErrConnection},
+		{errors.New("something else"), ErrorKindUnknown, nil},
+	}
+
+	for _, c := range cases {
+		err := classifyError(fmt.Errorf("Failed to retrieve: %w", c.err))
+		if err.Kind != c.kind {
+			t.Fatalf("%v: expected kind %v, got %v", c.err, c.kind, err.Kind)
+		}
+		if c.sentinel != nil && !errors.Is(err, c.sentinel) {
+			t.Fatalf("%v: does not match %v", c.err, c.sentinel)
+		}
+		if !errors.Is(err, c.err) {
+			t.Fatalf("%v: does not wrap the original error", c.err)
+		}
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<html><body>nginx</body></html>")
+	}))
+	defer srv.Close()
+
+	defs := `{"categories": {}, "technologies": {"Nginx": {"html": "nginx"}}}`
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+	if !errors.Is(result.Error, ErrHTTPStatus) || result.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected http status error, got %v (%v)", result.Error, result.StatusCode)
+	}
+	if len(result.Matches) != 1 {
+		t.Fatalf("matches of error pages should be kept")
+	}
+
+	b, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("could not marshal result: %v", err)
+	}
+	if !strings.Contains(string(b), `"error":{"kind":"http_status","message":"unexpected status 404"}`) {
+		t.Fatalf("error not serialised: %s", b)
+	}
+
+	result, _ = wa.Process(NewOnlineJob(srv.URL+"/slow", "", nil, 0, false, false))
+	if !errors.Is(result.Error, ErrTimeout) {
+		t.Fatalf("expected timeout error, got %v", result.Error)
+	}
+
+	result, _ = wa.Process(NewOnlineJob("http://[::1", "", nil, 0, false, false))
+	if !errors.Is(result.Error, ErrParse) {
+		t.Fatalf("expected parse error, got %v", result.Error)
+	}
+}
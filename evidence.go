@@ -0,0 +1,81 @@
+package webanalyze
+
+import "strings"
+
+// Sources of evidence, see Evidence
+const (
+	SourceHTML       = "html"
+	SourceHeader     = "header"
+	SourceCookie     = "cookie"
+	SourceMeta       = "meta"
+	SourceURL        = "url"
+	SourceScriptSrc  = "scriptSrc"
+	SourceScript     = "script"
+	SourceJS         = "js"
+	SourceDOM        = "dom"
+	SourceCertIssuer = "certIssuer"
+	SourceDNS        = "dns"
+	SourceRobots     = "robots"
+)
+
+// Evidence records a pattern of an app which matched a page
+type Evidence struct {
+	Source string `json:"source"`
+
+	// Key is the header, cookie, meta or javascript property name, the
+	// script url or the dom selector the pattern matched on
+	Key string `json:"key,omitempty"`
+
+	// Offset is the byte offset of the first match in html or script content
+	Offset int `json:"offset,omitempty"`
+
+	Pattern    string `json:"pattern"`
+	Match      string `json:"match"`
+	Version    string `json:"version,omitempty"`
+	Confidence int    `json:"confidence"`
+
+	submatches [][]string
+}
+
+// patternID identifies the pattern which produced the evidence. The key of
+// scriptSrc evidence is the matched url and not part of the pattern.
+func (e Evidence) patternID() string {
+	key := e.Key
+	if e.Source == SourceScriptSrc {
+		key = ""
+	}
+	return e.Source + "\x00" + key + "\x00" + e.Pattern
+}
+
+// findMatches runs a list of regexes on content and returns the evidence
+// of each regex which matched
+func findMatches(content string, regexes []AppRegexp, source, key string) []Evidence {
+	var evidence []Evidence
+
+	for _, r := range regexes {
+		matches := r.Regexp.FindAllStringSubmatch(content, -1)
+		if matches == nil {
+			continue
+		}
+
+		e := Evidence{
+			Source:     source,
+			Key:        key,
+			Pattern:    r.Pattern,
+			Match:      matches[0][0],
+			Confidence: r.Confidence,
+			submatches: matches,
+		}
+
+		if source == SourceHTML || source == SourceScript {
+			e.Offset = strings.Index(content, e.Match)
+		}
+
+		if r.Version != "" {
+			e.Version = findVersion(matches, r.Version)
+		}
+
+		evidence = append(evidence, e)
+	}
+	return evidence
+}
@@ -0,0 +1,47 @@
+package webanalyze
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProcessEvidence(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"Nginx": {"cats": [1], "headers": {"Server": "nginx(?:/([\\d.]+))?\\;version:\\1"}},
+		"PHP": {"cats": [1], "cookies": {"PHPSESSID": ""}},
+		"WordPress": {"cats": [1], "html": "wp-content", "meta": {"generator": "^WordPress"}},
+		"jQuery": {"cats": [1], "scriptSrc": "jquery-([\\d.]+)\\.js\\;version:\\1"}
+	}`)
+
+	body := `<html><head><meta name="generator" content="WordPress 6.1">
+	<script src="/jquery-3.6.0.js"></script></head><body>wp-content</body></html>`
+	job := NewOfflineJob("http://example.com", body, map[string][]string{"Server": {"nginx/1.25.3"}})
+	job.Cookies = []*http.Cookie{{Name: "PHPSESSID", Value: "abc"}}
+
+	result, _ := wa.Process(job)
+
+	expected := map[string][]Evidence{
+		"Nginx":     {{Source: SourceHeader, Key: "Server", Pattern: "nginx(?:/([\\d.]+))?", Match: "nginx/1.25.3", Version: "1.25.3"}},
+		"PHP":       {{Source: SourceCookie, Key: "PHPSESSID", Pattern: ".*", Match: "abc"}},
+		"WordPress": {{Source: SourceHTML, Pattern: "wp-content", Match: "wp-content", Offset: strings.Index(body, "wp-content")}, {Source: SourceMeta, Key: "generator", Pattern: "^WordPress", Match: "WordPress"}},
+		"jQuery":    {{Source: SourceScriptSrc, Key: "/jquery-3.6.0.js", Pattern: "jquery-([\\d.]+)\\.js", Match: "jquery-3.6.0.js", Version: "3.6.0"}},
+	}
+
+	if len(result.Matches) != len(expected) {
+		t.Fatalf("expected %v matches, got %v", len(expected), len(result.Matches))
+	}
+
+	for _, m := range result.Matches {
+		evidence := expected[m.AppName]
+		if len(m.Evidence) != len(evidence) {
+			t.Fatalf("%v: expected %v evidence, got %v", m.AppName, len(evidence), len(m.Evidence))
+		}
+		for i, e := range m.Evidence {
+			if e.Source != evidence[i].Source || e.Key != evidence[i].Key || e.Offset != evidence[i].Offset ||
+				e.Pattern != evidence[i].Pattern || e.Match != evidence[i].Match || e.Version != evidence[i].Version {
+				t.Fatalf("%v: expected evidence %+v, got %+v", m.AppName, evidence[i], e)
+			}
+		}
+	}
+}
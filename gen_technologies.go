@@ -0,0 +1,86 @@
+//go:build ignore
+// +build ignore
+
+// gen_technologies downloads the current technology definitions and
+// writes the snapshot embedded by embed.go, see go generate
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/rverton/webanalyze"
+)
+
+// snapshotFields must be used by at least one downloaded app, otherwise the
+// download is considered lossy and no snapshot is written
+var snapshotFields = []string{"js", "dom", "scriptSrc", "meta", "headers", "cookies", "implies", "excludes", "requires", "cpe"}
+
+func main() {
+	tmp, err := ioutil.TempFile("", "technologies-*.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := webanalyze.DownloadFile(tmp.Name()); err != nil {
+		log.Fatalf("could not download technologies: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := validate(data); err != nil {
+		log.Fatalf("refusing to write snapshot: %v", err)
+	}
+
+	f, err := os.Create("technologies.json.gz")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	// the modification time of the gzip header is the snapshot date
+	gz, _ := gzip.NewWriterLevel(f, gzip.BestCompression)
+	gz.ModTime = time.Now()
+	if _, err := gz.Write(data); err != nil {
+		log.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// validate ensures the definitions kept the fields used for detection
+func validate(data []byte) error {
+	var defs struct {
+		Apps map[string]map[string]json.RawMessage `json:"technologies"`
+	}
+	if err := json.Unmarshal(data, &defs); err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, app := range defs.Apps {
+		for field, value := range app {
+			if string(value) != "null" && string(value) != `""` {
+				used[field] = true
+			}
+		}
+	}
+
+	for _, field := range snapshotFields {
+		if !used[field] {
+			return fmt.Errorf("no technology uses %v", field)
+		}
+	}
+	return nil
+}
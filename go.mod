@@ -1,9 +1,12 @@
 module github.com/rverton/webanalyze
 
-go 1.15
+go 1.16
 
 require (
 	github.com/PuerkitoBio/goquery v1.6.0
+	github.com/andybalholm/cascadia v1.1.0
 	github.com/bobesa/go-domain-util v0.0.0-20190911083921-4033b5f7dd89
-	golang.org/x/net v0.7.0 // indirect
+	github.com/dlclark/regexp2 v1.12.0
+	github.com/tdewolff/parse/v2 v2.8.16
+	golang.org/x/net v0.7.0
 )
@@ -19,8 +19,10 @@ type Job struct {
 	Cookies          []*http.Cookie
 	Crawl            int
 	SearchSubdomain  bool
+	FetchScripts     bool // download external scripts for js matching
 	forceNotDownload bool
 	followRedirect   bool
+	depth            int // crawl depth, set by the Scanner
 }
 
 // NewOfflineJob constructs a job out of the constituents of a
@@ -0,0 +1,259 @@
+package webanalyze
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// maxScriptSize limits the size of a single script body passed to the
+// javascript parser
+const maxScriptSize = 2 << 20
+
+// maxJSProperties limits the number of paths and aliases recorded for all
+// scripts of a page
+const maxJSProperties = 100000
+
+// maxJSAliasDepth limits how many aliases are followed by a lookup, which
+// also ends lookups in alias cycles like a = b; b = a
+const maxJSAliasDepth = 16
+
+// jsProperties holds the dotted property paths (e.g. jQuery.fn.jquery)
+// found in script bodies with their literal value. Paths which are
+// assigned to but have no literal value (objects, functions, ...) are
+// mapped to an empty string, so that their existence can still be
+// checked. Assignments of variables are stored as aliases, which are
+// resolved by lookup.
+type jsProperties struct {
+	values  map[string]string
+	aliases map[string]string
+}
+
+// lookup returns the value of path, following aliases of the path and of
+// its parents
+func (p jsProperties) lookup(path string) (string, bool) {
+	return p.resolve(path, 0)
+}
+
+func (p jsProperties) resolve(path string, depth int) (string, bool) {
+	if v, ok := p.values[path]; ok {
+		return v, true
+	}
+	if depth >= maxJSAliasDepth {
+		return "", false
+	}
+
+	// the longest aliased prefix wins, e.g. for x.a.b with x = y the path
+	// is resolved as y.a.b
+	for i := len(path); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+		target, ok := p.aliases[path[:i]]
+		if !ok {
+			continue
+		}
+
+		v, found := p.resolve(target+path[i:], depth+1)
+		if !found && i == len(path) {
+			// an alias of an unknown variable still exists
+			return "", true
+		}
+		return v, found
+	}
+
+	return "", false
+}
+
+func (p jsProperties) full() bool {
+	return len(p.values)+len(p.aliases) >= maxJSProperties
+}
+
+// jsExtractor walks a javascript AST and records all assignments and
+// variable declarations which can be resolved to a static property path
+type jsExtractor struct {
+	props jsProperties
+}
+
+// extractJSProperties statically evaluates the given script bodies and
+// returns all global property paths it was able to resolve. Scripts which
+// can not be parsed are skipped.
+func extractJSProperties(scripts []string) jsProperties {
+	e := &jsExtractor{props: jsProperties{
+		values:  make(map[string]string),
+		aliases: make(map[string]string),
+	}}
+
+	for _, script := range scripts {
+		if script == "" || len(script) > maxScriptSize {
+			continue
+		}
+
+		ast, err := js.Parse(parse.NewInputString(script), js.Options{})
+		if err != nil {
+			continue
+		}
+
+		js.Walk(e, ast)
+	}
+
+	return e.props
+}
+
+// Enter implements js.IVisitor
+func (e *jsExtractor) Enter(n js.INode) js.IVisitor {
+	switch n := n.(type) {
+	case *js.BinaryExpr:
+		if n.Op == js.EqToken {
+			e.assign(n)
+		}
+	case *js.VarDecl:
+		for _, item := range n.List {
+			v, ok := item.Binding.(*js.Var)
+			if !ok || item.Default == nil {
+				continue
+			}
+			e.record(string(v.Name()), item.Default)
+		}
+	}
+
+	return e
+}
+
+// Exit implements js.IVisitor
+func (e *jsExtractor) Exit(n js.INode) {}
+
+// assign handles (chained) assignments like a.b = c.d = "value"
+func (e *jsExtractor) assign(n *js.BinaryExpr) {
+	value := n.Y
+	for {
+		next, ok := value.(*js.BinaryExpr)
+		if !ok || next.Op != js.EqToken {
+			break
+		}
+		value = next.Y
+	}
+
+	if path, ok := jsPath(n.X); ok {
+		e.record(path, value)
+	}
+}
+
+// record stores the value of expr under path. Object literals are
+// flattened, so that {a: {b: "1"}} assigned to x results in x.a.b = "1".
+func (e *jsExtractor) record(path string, expr js.IExpr) {
+	path = strings.TrimPrefix(path, "window.")
+
+	if e.props.full() {
+		return
+	}
+
+	switch v := expr.(type) {
+	case *js.GroupExpr:
+		e.record(path, v.X)
+		return
+	case *js.Var:
+		// refer to the variable instead of copying what is known about it,
+		// self references like a.b = a are ignored
+		name := string(v.Name())
+		if name != path && !strings.HasPrefix(path, name+".") {
+			delete(e.props.values, path)
+			e.props.aliases[path] = name
+			return
+		}
+	}
+
+	delete(e.props.aliases, path)
+	if _, ok := e.props.values[path]; !ok {
+		e.props.values[path] = ""
+	}
+
+	switch v := expr.(type) {
+	case *js.LiteralExpr:
+		if s, ok := jsLiteral(v); ok {
+			e.props.values[path] = s
+		}
+	case *js.ObjectExpr:
+		for _, prop := range v.List {
+			if prop.Name == nil || prop.Name.IsComputed() || prop.Value == nil {
+				continue
+			}
+			key, ok := jsPropertyName(prop.Name.Literal)
+			if !ok {
+				continue
+			}
+			e.record(path+"."+key, prop.Value)
+		}
+	}
+}
+
+// jsPath converts a member expression into its dotted path
+func jsPath(expr js.IExpr) (string, bool) {
+	switch v := expr.(type) {
+	case *js.Var:
+		return string(v.Name()), true
+	case *js.GroupExpr:
+		return jsPath(v.X)
+	case *js.DotExpr:
+		base, ok := jsPath(v.X)
+		if !ok {
+			return "", false
+		}
+		switch y := v.Y.(type) {
+		case *js.Var:
+			return base + "." + string(y.Name()), true
+		case js.LiteralExpr:
+			return base + "." + string(y.Data), true
+		case *js.LiteralExpr:
+			return base + "." + string(y.Data), true
+		}
+	case *js.IndexExpr:
+		base, ok := jsPath(v.X)
+		if !ok {
+			return "", false
+		}
+		if lit, ok := v.Y.(*js.LiteralExpr); ok && lit.TokenType == js.StringToken {
+			return base + "." + unquoteJS(lit.Data), true
+		}
+	}
+
+	return "", false
+}
+
+// jsPropertyName returns the key of an object literal property
+func jsPropertyName(lit js.LiteralExpr) (string, bool) {
+	switch lit.TokenType {
+	case js.StringToken:
+		return unquoteJS(lit.Data), true
+	case js.ErrorToken:
+		return "", false
+	}
+	return string(lit.Data), true
+}
+
+// jsLiteral returns the string representation of literal values
+func jsLiteral(lit *js.LiteralExpr) (string, bool) {
+	switch lit.TokenType {
+	case js.StringToken:
+		return unquoteJS(lit.Data), true
+	case js.DecimalToken, js.IntegerToken, js.BinaryToken, js.OctalToken, js.HexadecimalToken,
+		js.TrueToken, js.FalseToken:
+		return string(lit.Data), true
+	}
+	return "", false
+}
+
+func unquoteJS(data []byte) string {
+	if len(data) < 2 {
+		return string(data)
+	}
+
+	s := string(data[1 : len(data)-1])
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	if unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(s, `"`, `\"`) + `"`); err == nil {
+		return unquoted
+	}
+	return s
+}
@@ -0,0 +1,102 @@
+package webanalyze
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractJSProperties(t *testing.T) {
+	script := `
+	(function(window) {
+		var version = "3.5.1";
+		var jQuery = function() {};
+		jQuery.fn = jQuery.prototype = { jquery: version, length: 0 };
+		window.jQuery = window.$ = jQuery;
+	})(window);
+	var Vue = { version: '2.6.14', config: { devtools: true } };
+	window["Shopify"] = {};
+	`
+
+	props := extractJSProperties([]string{script})
+
+	expected := map[string]string{
+		"jQuery.fn.jquery":    "3.5.1",
+		"Vue.version":         "2.6.14",
+		"Vue.config.devtools": "true",
+		"Shopify":             "",
+	}
+
+	for path, value := range expected {
+		v, ok := props.lookup(path)
+		if !ok {
+			t.Fatalf("property %v not found", path)
+		}
+		if v != value {
+			t.Fatalf("property %v: expected %q, got %q", path, value, v)
+		}
+	}
+}
+
+func TestExtractJSPropertiesInvalid(t *testing.T) {
+	props := extractJSProperties([]string{"var a = ;", "var b = 1;"})
+
+	if _, ok := props.lookup("a"); ok {
+		t.Fatalf("property of invalid script should not be extracted")
+	}
+
+	if v, _ := props.lookup("b"); v != "1" {
+		t.Fatalf("valid script was not evaluated")
+	}
+}
+
+func TestExtractJSPropertiesAliases(t *testing.T) {
+	var script strings.Builder
+	script.WriteString("var a = {version: '1.2'};\n")
+	for i := 0; i < 40; i++ {
+		fmt.Fprintf(&script, "a.p%d = a;\n", i)
+	}
+	script.WriteString("var b = a; var c = b; b.d = c; var e = f; var f = e;\n")
+
+	start := time.Now()
+	props := extractJSProperties([]string{script.String()})
+	if time.Since(start) > time.Second {
+		t.Fatalf("self references took %v", time.Since(start))
+	}
+
+	if n := len(props.values) + len(props.aliases); n > 100 {
+		t.Fatalf("self references created %v properties", n)
+	}
+
+	expected := map[string]string{
+		"a.version":   "1.2",
+		"c.version":   "1.2",
+		"b.d.version": "1.2",
+		"a.p3":        "",
+	}
+	for path, value := range expected {
+		if v, ok := props.lookup(path); !ok || v != value {
+			t.Fatalf("property %v: expected %q, got %q (%v)", path, value, v, ok)
+		}
+	}
+
+	// cycles end at the alias depth limit
+	if _, ok := props.lookup("e.version"); ok {
+		t.Fatalf("property of alias cycle found")
+	}
+}
+
+func TestExtractJSPropertiesLimit(t *testing.T) {
+	var script strings.Builder
+	script.WriteString("var o = {")
+	for i := 0; i < maxJSProperties+10; i++ {
+		fmt.Fprintf(&script, "k%d: 1, ", i)
+	}
+	script.WriteString("};")
+
+	props := extractJSProperties([]string{script.String()})
+	if n := len(props.values) + len(props.aliases); n != maxJSProperties {
+		t.Fatalf("expected %v properties, got %v", maxJSProperties, n)
+	}
+}
@@ -0,0 +1,255 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severities of lint issues
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue describes a single problem found in a technologies file
+type LintIssue struct {
+	App      string `json:"app,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+func (i LintIssue) String() string {
+	var parts []string
+	if i.App != "" {
+		parts = append(parts, i.App)
+	}
+	if i.Field != "" {
+		parts = append(parts, i.Field)
+	}
+	parts = append(parts, i.Message)
+	return i.Severity + ": " + strings.Join(parts, ": ")
+}
+
+// definitionKeys lists the keys of the upstream technology schema which
+// are valid even if they are not (yet) used by webanalyze
+var definitionKeys = []string{
+	"cats", "website", "description", "icon", "cpe", "saas", "oss", "pricing",
+	"implies", "requires", "requiresCategory", "excludes", "cookies", "dom",
+	"dns", "js", "headers", "text", "css", "probe", "robots", "url", "xhr",
+	"meta", "scriptSrc", "scripts", "html", "certIssuer",
+}
+
+// ternaryReference matches the start of a ternary version template
+var ternaryReference = regexp.MustCompile(`\\\d+\?`)
+
+// Lint validates a technologies file and returns all issues found. An
+// error is only returned if the file can not be decoded at all.
+func Lint(r io.Reader) ([]LintIssue, error) {
+	var raw map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	add := func(app, field, severity, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{
+			App:      app,
+			Field:    field,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for key := range raw {
+		if key != "technologies" && key != "categories" {
+			add("", key, LintWarning, "unknown top-level key")
+		}
+	}
+
+	var cats map[string]Category
+	if err := json.Unmarshal(raw["categories"], &cats); err != nil {
+		add("", "categories", LintError, "invalid categories: %v", err)
+	}
+
+	var rawApps map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(raw["technologies"], &rawApps); err != nil {
+		return nil, fmt.Errorf("invalid technologies: %w", err)
+	}
+
+	known := make(map[string]bool)
+	for _, key := range definitionKeys {
+		known[key] = true
+	}
+	t := reflect.TypeOf(App{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			known[tag] = true
+		}
+	}
+
+	apps := make(map[string]App)
+	for name, fields := range rawApps {
+		for key := range fields {
+			if !known[key] {
+				add(name, key, LintWarning, "unknown key")
+			}
+		}
+
+		data, _ := json.Marshal(fields)
+
+		var app App
+		if err := json.Unmarshal(data, &app); err != nil {
+			add(name, "", LintError, "invalid definition: %v", err)
+			continue
+		}
+
+		pc := &patternCompiler{app: name, fallback: DefaultRegexpEngine}
+		pc.compileApp(&app)
+		apps[name] = app
+
+		for _, d := range pc.degraded {
+			field := d.Field
+			if d.Key != "" {
+				field += "." + d.Key
+			}
+			switch d.Status {
+			case PatternDropped:
+				add(name, field, LintError, "invalid regex %q: %v", d.Pattern, d.Error)
+			default:
+				add(name, field, LintWarning, "regex %q is not supported by RE2 (%v)", d.Pattern, d.Status)
+			}
+		}
+
+		for field, regexes := range app.regexes() {
+			for _, re := range regexes {
+				if err := validateVersionTemplate(re.Version, numSubexp(re.Regexp)); err != nil {
+					add(name, field, LintError, "invalid version template %q: %v", re.Version, err)
+				}
+			}
+		}
+	}
+
+	for name, app := range apps {
+		for _, cid := range app.Cats {
+			if _, ok := cats[cid]; !ok {
+				add(name, "cats", LintError, "unknown category id %v", cid)
+			}
+		}
+		for _, cid := range app.RequiresCategory {
+			if _, ok := cats[cid]; !ok {
+				add(name, "requiresCategory", LintError, "unknown category id %v", cid)
+			}
+		}
+
+		targets := map[string]StringArray{
+			"implies":  app.Implies,
+			"excludes": app.Excludes,
+			"requires": app.Requires,
+		}
+		for field, names := range targets {
+			for _, target := range names {
+				target, _, _ = parsePattern(target)
+				if _, ok := rawApps[target]; !ok {
+					add(name, field, LintError, "unknown technology %q", target)
+				}
+			}
+		}
+	}
+
+	for _, cycle := range impliesCycles(apps) {
+		add(cycle[0], "implies", LintError, "implication cycle: %v", strings.Join(cycle, " -> "))
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].App != issues[j].App {
+			return issues[i].App < issues[j].App
+		}
+		return issues[i].Field < issues[j].Field
+	})
+
+	return issues, nil
+}
+
+// validateVersionTemplate checks that all back references of a version
+// template exist in the pattern and that ternaries are complete. If
+// groups is negative, the number of capture groups is unknown.
+func validateVersionTemplate(template string, groups int) error {
+	for _, ref := range backReference.FindAllStringSubmatch(template, -1) {
+		n, _ := strconv.Atoi(ref[1])
+		if n == 0 || (groups >= 0 && n > groups) {
+			return fmt.Errorf("reference \\%v does not exist", n)
+		}
+	}
+
+	for _, loc := range ternaryReference.FindAllStringIndex(template, -1) {
+		if !strings.Contains(template[loc[1]:], ":") {
+			return fmt.Errorf("ternary without else branch")
+		}
+	}
+
+	return nil
+}
+
+// impliesCycles returns all implication cycles, each starting and ending
+// with the same technology
+func impliesCycles(apps map[string]App) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	var cycles [][]string
+	state := make(map[string]int)
+	var stack []string
+
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+
+		for _, implied := range apps[name].Implies {
+			implied, _, _ = parsePattern(implied)
+			if _, ok := apps[implied]; !ok {
+				continue
+			}
+
+			switch state[implied] {
+			case visiting:
+				for i := len(stack) - 1; i >= 0; i-- {
+					if stack[i] == implied {
+						cycle := append([]string{}, stack[i:]...)
+						cycles = append(cycles, append(cycle, implied))
+						break
+					}
+				}
+			case unvisited:
+				visit(implied)
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+
+	for _, name := range names {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	return cycles
+}
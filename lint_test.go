@@ -0,0 +1,52 @@
+package webanalyze
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	defs := `{
+		"categories": {"1": {"name": "CMS"}},
+		"technologies": {
+			"A": {"cats": [1, 99], "html": "(unbalanced", "implies": "B\\;confidence:50", "heders": {}},
+			"B": {"cats": [1], "html": "b-([\\d.]+)\\;version:\\2", "implies": "A", "excludes": "Missing"},
+			"C": {"cats": [1], "html": "c(\\d)\\;version:\\1?new:", "js": {"C.version": "(?=x)"}},
+			"D": {"cats": "invalid", "html": {}}
+		},
+		"extra": true
+	}`
+
+	issues, err := Lint(strings.NewReader(defs))
+	if err != nil {
+		t.Fatalf("lint failed: %v", err)
+	}
+
+	expected := []string{
+		"warning: extra: unknown top-level key",
+		"error: A: cats: unknown category id 99",
+		"warning: A: heders: unknown key",
+		"error: A: html: invalid regex",
+		"error: A: implies: implication cycle: A -> B -> A",
+		"error: B: excludes: unknown technology \"Missing\"",
+		"error: B: html: invalid version template",
+		"warning: C: js.C.version: regex",
+		"error: D: invalid definition",
+	}
+
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.String())
+	}
+	all := strings.Join(lines, "\n")
+
+	for _, e := range expected {
+		if !strings.Contains(all, e) {
+			t.Fatalf("expected issue %q, got:\n%v", e, all)
+		}
+	}
+
+	if len(issues) != len(expected) {
+		t.Fatalf("expected %v issues, got:\n%v", len(expected), all)
+	}
+}
@@ -0,0 +1,177 @@
+package webanalyze
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a WebAnalyzer, see NewWebAnalyzerWithOptions
+type Option func(*WebAnalyzer)
+
+// WithHTTPClient uses client for all requests. The timeout, TLS, redirect
+// and proxy options only apply to the default client and are ignored.
+func WithHTTPClient(client *http.Client) Option {
+	return func(wa *WebAnalyzer) {
+		wa.client = client
+	}
+}
+
+// WithUserAgent sets the User-Agent header of all requests
+func WithUserAgent(userAgent string) Option {
+	return func(wa *WebAnalyzer) {
+		wa.headers.Set("User-Agent", userAgent)
+	}
+}
+
+// WithHeader adds a header to all requests. Setting Accept replaces the
+// default of */*.
+func WithHeader(key, value string) Option {
+	return func(wa *WebAnalyzer) {
+		if http.CanonicalHeaderKey(key) == "Accept" {
+			wa.headers.Del(key)
+		}
+		wa.headers.Add(key, value)
+	}
+}
+
+// WithMaxBodySize limits the number of bytes read from a response body
+// (default 10MB), longer bodies are truncated and flagged in the Result.
+// Zero or less reads bodies without limit.
+func WithMaxBodySize(n int64) Option {
+	return func(wa *WebAnalyzer) {
+		wa.maxBodySize = n
+	}
+}
+
+// WithTimeout sets the timeout of a single request including reading the
+// body (default 8s)
+func WithTimeout(d time.Duration) Option {
+	return func(wa *WebAnalyzer) {
+		wa.timeout = d
+	}
+}
+
+// WithTLSVerification enables verification of server certificates, which
+// is disabled by default to analyze misconfigured hosts as well
+func WithTLSVerification(verify bool) Option {
+	return func(wa *WebAnalyzer) {
+		wa.verifyTLS = verify
+	}
+}
+
+// WithRedirectPolicy sets the CheckRedirect function of the http client.
+// By default only redirects to the same host (e.g. http to https) are
+// followed.
+func WithRedirectPolicy(policy func(req *http.Request, via []*http.Request) error) Option {
+	return func(wa *WebAnalyzer) {
+		wa.checkRedirect = policy
+	}
+}
+
+// WithProxy sets the proxy function of the http transport, e.g.
+// http.ProxyURL. By default the proxy is taken from the environment.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(wa *WebAnalyzer) {
+		wa.proxy = proxy
+	}
+}
+
+// WithRegexpEngine sets the fallback engine for patterns which Go's regexp
+// package does not support, see DefaultRegexpEngine
+func WithRegexpEngine(engine RegexpEngine) Option {
+	return func(wa *WebAnalyzer) {
+		wa.regexpEngine = engine
+	}
+}
+
+// WithFullMatches marshals matches to JSON including the complete app
+// definition instead of the compact representation of name, version,
+// categories, confidence, metadata and evidence
+func WithFullMatches(full bool) Option {
+	return func(wa *WebAnalyzer) {
+		wa.fullMatches = full
+	}
+}
+
+// WithMatchOrder sets the order of Result.Matches to one of OrderByName
+// (default), OrderByCategory or OrderByConfidence
+func WithMatchOrder(order string) Option {
+	return func(wa *WebAnalyzer) {
+		wa.matchOrder = order
+	}
+}
+
+// WithVulnDB attaches the vulnerabilities of db to all matches with a
+// detected version and a CPE
+func WithVulnDB(db *VulnDB) Option {
+	return func(wa *WebAnalyzer) {
+		wa.vulnDB = db
+	}
+}
+
+// WithResolver looks up the dns records of scanned domains with r instead
+// of the system resolver. A nil resolver disables dns fingerprints.
+func WithResolver(r Resolver) Option {
+	return func(wa *WebAnalyzer) {
+		wa.resolver = r
+	}
+}
+
+// WithRobots fetches the robots.txt of each scanned origin once and
+// matches it against robots fingerprints (default false)
+func WithRobots(fetch bool) Option {
+	return func(wa *WebAnalyzer) {
+		wa.fetchRobots = fetch
+	}
+}
+
+// NewWebAnalyzerWithOptions initializes webanalyzer by passing a reader of
+// the app definition and options configuring how hosts are requested
+func NewWebAnalyzerWithOptions(apps io.Reader, opts ...Option) (*WebAnalyzer, error) {
+	wa := &WebAnalyzer{
+		regexpEngine:  DefaultRegexpEngine,
+		matchOrder:    OrderByName,
+		headers:       http.Header{"Accept": {"*/*"}},
+		maxBodySize:   defaultMaxBodySize,
+		timeout:       timeout,
+		checkRedirect: sameHostRedirect,
+		proxy:         http.ProxyFromEnvironment,
+		resolver:      &NetResolver{Resolver: net.DefaultResolver},
+		dns:           newLRUCache(dnsCacheSize, dnsCacheTTL),
+		robots:        newLRUCache(robotsCacheSize, robotsCacheTTL),
+	}
+
+	for _, opt := range opts {
+		opt(wa)
+	}
+
+	if err := wa.loadApps(apps); err != nil {
+		return nil, err
+	}
+
+	if wa.client == nil {
+		wa.client = &http.Client{
+			Timeout: wa.timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: !wa.verifyTLS},
+				Proxy:           wa.proxy,
+			},
+			CheckRedirect: wa.checkRedirect,
+		}
+	}
+
+	return wa, nil
+}
+
+// sameHostRedirect only allows redirects on the same host, e.g. from
+// http to https
+func sameHostRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) == 0 || via[0].URL.Hostname() != req.URL.Hostname() {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
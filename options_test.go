@@ -0,0 +1,53 @@
+package webanalyze
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		fmt.Fprintf(w, "%v|%v|%v|", r.UserAgent(), r.Header.Get("X-Scan"), r.Header.Get("Accept"))
+		fmt.Fprint(w, strings.Repeat("x", 1000)+"tail")
+	}))
+	defer srv.Close()
+
+	defs := `{"categories": {}, "technologies": {
+		"Agent": {"html": "^scanner/1\\.0\\|team\\|text/html\\|"},
+		"Tail": {"html": "tail"}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs),
+		WithUserAgent("scanner/1.0"),
+		WithHeader("X-Scan", "team"),
+		WithHeader("Accept", "text/html"),
+		WithMaxBodySize(500),
+		WithRedirectPolicy(func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}),
+	)
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+	names := matchNames(result.Matches)
+	if !names["Agent"] {
+		t.Fatalf("user agent and headers not sent")
+	}
+	if names["Tail"] {
+		t.Fatalf("body was not limited")
+	}
+
+	result, _ = wa.Process(NewOnlineJob(srv.URL+"/redirect", "", nil, 0, false, false))
+	if len(result.Matches) != 0 {
+		t.Fatalf("redirect policy was not applied")
+	}
+}
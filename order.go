@@ -0,0 +1,62 @@
+package webanalyze
+
+import (
+	"sort"
+	"strings"
+)
+
+// Orders of Result.Matches, see WithMatchOrder
+const (
+	OrderByName       = "name"
+	OrderByCategory   = "category"
+	OrderByConfidence = "confidence"
+)
+
+// SortMatches sorts matches by app name, by category name or by descending
+// confidence. Ties are broken by app name, so the order is stable across
+// runs. Unknown orders sort by name.
+func SortMatches(matches []Match, order string) {
+	byName := func(i, j int) bool {
+		return strings.ToLower(matches[i].AppName) < strings.ToLower(matches[j].AppName)
+	}
+
+	less := byName
+	switch order {
+	case OrderByCategory:
+		less = func(i, j int) bool {
+			ci, cj := firstCategory(matches[i]), firstCategory(matches[j])
+			if ci != cj {
+				return ci < cj
+			}
+			return byName(i, j)
+		}
+	case OrderByConfidence:
+		less = func(i, j int) bool {
+			if matches[i].Confidence != matches[j].Confidence {
+				return matches[i].Confidence > matches[j].Confidence
+			}
+			return byName(i, j)
+		}
+	}
+
+	sort.SliceStable(matches, less)
+}
+
+// firstCategory returns the lowercased first category name of a match
+func firstCategory(m Match) string {
+	if len(m.CatNames) == 0 {
+		return ""
+	}
+	return strings.ToLower(m.CatNames[0])
+}
+
+// sortedKeys returns the keys of a pattern map in sorted order, so that
+// patterns are compiled and evaluated deterministically
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,70 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSortMatches(t *testing.T) {
+	matches := []Match{
+		{AppName: "nginx", Confidence: 50, App: App{CatNames: []string{"Web servers"}}},
+		{AppName: "WordPress", Confidence: 100, App: App{CatNames: []string{"CMS"}}},
+		{AppName: "PHP", Confidence: 50, App: App{CatNames: []string{"Programming languages"}}},
+		{AppName: "Drupal", Confidence: 100, App: App{CatNames: []string{"CMS"}}},
+	}
+
+	expected := map[string]string{
+		OrderByName:       "Drupal,nginx,PHP,WordPress",
+		OrderByCategory:   "Drupal,WordPress,PHP,nginx",
+		OrderByConfidence: "Drupal,WordPress,nginx,PHP",
+	}
+
+	for order, names := range expected {
+		SortMatches(matches, order)
+
+		var sorted []string
+		for _, m := range matches {
+			sorted = append(sorted, m.AppName)
+		}
+		if strings.Join(sorted, ",") != names {
+			t.Fatalf("%v: expected %v, got %v", order, names, sorted)
+		}
+	}
+}
+
+func TestProcessDeterministic(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"WordPress": {"cats": [1], "html": "wp-content", "implies": ["PHP", "MySQL"], "headers": {"X-Powered-By": "wp", "Link": "wp-json"}},
+		"PHP": {"cats": [2], "headers": {"X-Powered-By": "php", "Set-Cookie": "PHPSESSID"}},
+		"MySQL": {"cats": [2]},
+		"Nginx": {"cats": [2], "headers": {"Server": "nginx"}},
+		"Akamai": {"cats": [2], "headers": {"Server": "akamai"}}
+	}`)
+
+	headers := map[string][]string{
+		"Server":       {"nginx akamai"},
+		"X-Powered-By": {"php wp"},
+		"Link":         {"wp-json"},
+		"Set-Cookie":   {"PHPSESSID=1"},
+	}
+
+	var first string
+	for i := 0; i < 20; i++ {
+		result, _ := wa.Process(NewOfflineJob("http://example.com", "wp-content", headers))
+
+		b, err := json.Marshal(result.Matches)
+		if err != nil {
+			t.Fatalf("could not marshal matches: %v", err)
+		}
+
+		if i == 0 {
+			first = string(b)
+			if len(result.Matches) != 5 {
+				t.Fatalf("expected one match per app, got %s", b)
+			}
+		} else if string(b) != first {
+			t.Fatalf("results differ between runs:\n%s\n%s", first, b)
+		}
+	}
+}
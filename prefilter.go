@@ -0,0 +1,279 @@
+package webanalyze
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+const (
+	// minLiteralLength is the minimum length of the literals a regex is
+	// indexed by, shorter literals occur on nearly every page
+	minLiteralLength = 3
+
+	// maxLiteralAlternatives limits the number of literals of which one
+	// must occur for a regex with alternations
+	maxLiteralAlternatives = 8
+)
+
+// literalIndex selects the regexes which can match a page by searching all
+// literals required by these regexes in a single pass (Aho-Corasick). A
+// regex whose literals do not occur in the content can not match it.
+type literalIndex struct {
+	literals map[string]int
+	nodes    []acNode
+}
+
+// acNode is a state of the Aho-Corasick automaton
+type acNode struct {
+	next   []acEdge
+	fail   int
+	output int // next node on the fail path which ends a literal, or -1
+	ends   []int
+}
+
+type acEdge struct {
+	b    byte
+	node int
+}
+
+func newLiteralIndex() *literalIndex {
+	return &literalIndex{
+		literals: make(map[string]int),
+		nodes:    []acNode{{output: -1}},
+	}
+}
+
+// index extracts the literals required by re and adds them to the index.
+// It returns their ids, of which at least one must be found for re to
+// match, or nil if re has to be evaluated on every page.
+func (idx *literalIndex) index(re Regexp) []int {
+	stdre, ok := re.(*regexp.Regexp)
+	if !ok {
+		return nil
+	}
+
+	parsed, err := syntax.Parse(stdre.String(), syntax.Perl)
+	if err != nil {
+		return nil
+	}
+
+	literals := requiredLiterals(parsed.Simplify())
+	if len(literals) == 0 || shortestLength(literals) < minLiteralLength {
+		return nil
+	}
+
+	ids := make([]int, 0, len(literals))
+	for _, lit := range literals {
+		ids = append(ids, idx.add(lit))
+	}
+	return ids
+}
+
+// add inserts a literal into the trie and returns its id
+func (idx *literalIndex) add(lit string) int {
+	if id, ok := idx.literals[lit]; ok {
+		return id
+	}
+
+	id := len(idx.literals)
+	idx.literals[lit] = id
+
+	node := 0
+	for i := 0; i < len(lit); i++ {
+		next := idx.child(node, lit[i])
+		if next < 0 {
+			next = len(idx.nodes)
+			idx.nodes = append(idx.nodes, acNode{output: -1})
+			idx.nodes[node].next = append(idx.nodes[node].next, acEdge{lit[i], next})
+		}
+		node = next
+	}
+	idx.nodes[node].ends = append(idx.nodes[node].ends, id)
+
+	return id
+}
+
+func (idx *literalIndex) child(node int, b byte) int {
+	for _, e := range idx.nodes[node].next {
+		if e.b == b {
+			return e.node
+		}
+	}
+	return -1
+}
+
+// build computes the fail links, it must be called after all literals
+// were added
+func (idx *literalIndex) build() {
+	queue := []int{}
+	for _, e := range idx.nodes[0].next {
+		queue = append(queue, e.node)
+	}
+
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		for _, e := range idx.nodes[node].next {
+			fail := idx.nodes[node].fail
+			for fail > 0 && idx.child(fail, e.b) < 0 {
+				fail = idx.nodes[fail].fail
+			}
+			if next := idx.child(fail, e.b); next >= 0 && next != e.node {
+				fail = next
+			} else {
+				fail = 0
+			}
+
+			idx.nodes[e.node].fail = fail
+			if len(idx.nodes[fail].ends) > 0 {
+				idx.nodes[e.node].output = fail
+			} else {
+				idx.nodes[e.node].output = idx.nodes[fail].output
+			}
+
+			queue = append(queue, e.node)
+		}
+	}
+}
+
+// find returns which literals occur in content
+func (idx *literalIndex) find(content string) []bool {
+	found := make([]bool, len(idx.literals))
+	reported := make([]bool, len(idx.nodes))
+
+	if strings.ContainsAny(content, "\u212A\u017F") {
+		content = foldRunes.Replace(content)
+	}
+
+	node := 0
+	for i := 0; i < len(content); i++ {
+		b := foldByte(content[i])
+
+		for node > 0 && idx.child(node, b) < 0 {
+			node = idx.nodes[node].fail
+		}
+		if next := idx.child(node, b); next >= 0 {
+			node = next
+		}
+
+		// a reported node has reported its whole output chain as well
+		for n := node; n > 0 && !reported[n]; n = idx.nodes[n].output {
+			reported[n] = true
+			for _, id := range idx.nodes[n].ends {
+				found[id] = true
+			}
+		}
+	}
+
+	return found
+}
+
+// foldByte lowercases ascii letters
+func foldByte(b byte) byte {
+	if 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// foldRunes replaces the only non-ascii runes which the regexp package case
+// folds to ascii letters, the kelvin sign and the long s
+var foldRunes = strings.NewReplacer("\u212A", "k", "\u017F", "s")
+
+// mayMatch reports whether one of the literals required by r was found
+func (r AppRegexp) mayMatch(found []bool) bool {
+	if r.literals == nil {
+		return true
+	}
+	for _, id := range r.literals {
+		if found[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiterals returns lowercased ascii literals of which at least one
+// occurs in every match of re, or nil if there are none
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		lit := string(re.Rune)
+		if !isASCII(lit) {
+			return nil
+		}
+		return []string{strings.ToLower(lit)}
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min < 1 {
+			return nil
+		}
+		return requiredLiterals(re.Sub[0])
+
+	case syntax.OpConcat:
+		var best []string
+		consider := func(literals []string) {
+			if len(literals) > 0 && (best == nil || shortestLength(literals) > shortestLength(best)) {
+				best = literals
+			}
+		}
+
+		// adjacent literals form a longer one
+		var run strings.Builder
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral && isASCII(string(sub.Rune)) {
+				run.WriteString(strings.ToLower(string(sub.Rune)))
+				continue
+			}
+			if run.Len() > 0 {
+				consider([]string{run.String()})
+				run.Reset()
+			}
+			consider(requiredLiterals(sub))
+		}
+		if run.Len() > 0 {
+			consider([]string{run.String()})
+		}
+		return best
+
+	case syntax.OpAlternate:
+		var literals []string
+		for _, sub := range re.Sub {
+			alternatives := requiredLiterals(sub)
+			if len(alternatives) == 0 {
+				return nil
+			}
+			literals = append(literals, alternatives...)
+		}
+		if len(literals) > maxLiteralAlternatives {
+			return nil
+		}
+		return literals
+	}
+
+	return nil
+}
+
+func shortestLength(literals []string) int {
+	shortest := len(literals[0])
+	for _, lit := range literals[1:] {
+		if len(lit) < shortest {
+			shortest = len(lit)
+		}
+	}
+	return shortest
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
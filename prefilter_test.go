@@ -0,0 +1,153 @@
+package webanalyze
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"testing"
+)
+
+const prefilterPage = `<!DOCTYPE html>
+<html lang="en-US"><head>
+<meta name="generator" content="WordPress 6.1.1">
+<link rel="stylesheet" href="/wp-content/themes/twentytwenty/style.css?ver=2.1">
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/bootstrap@5.2.3/dist/css/bootstrap.min.css">
+<script src="/wp-includes/js/jquery/jquery.min.js?ver=3.6.1"></script>
+<script async src="https://www.googletagmanager.com/gtag/js?id=G-XXXX"></script>
+<script>window.dataLayer = window.dataLayer || []; function gtag(){dataLayer.push(arguments);}</script>
+<link rel="preconnect" href="https://fonts.gstatic.com">
+</head>
+<body class="home page-template-default elementor-default elementor-kit-5">
+<div id="root" data-reactroot=""><div class="woocommerce">Shop</div></div>
+<div class="elementor-widget-container"><p>Powered by WordPress and Elementor</p></div>
+<!-- This site is optimized with the Yoast SEO plugin v19.12 -->
+<footer>&copy; 2022 <a href="https://www.shopify.com">Shopify</a> cdn.shopify.com Kubernetes</footer>
+</body></html>`
+
+func TestRequiredLiterals(t *testing.T) {
+	cases := map[string][]string{
+		"wp-content":                 {"wp-content"},
+		"/wp-content/themes/([^/]+)": {"/wp-content/themes/"},
+		"jquery[.-]([\\d.]+)\\.js":   {"jquery"},
+		"(?:shopify|bigcommerce)\\.": {"shopify", "bigcommerce"},
+		"<div[^>]+data-reactroot":    {"data-reactroot"},
+		"foo|ba":                     {"foo", "ba"},
+		"(?:foo)?bar":                {"bar"},
+		"[a-z]+":                     nil,
+		"a*b?":                       nil,
+		"x|[0-9]":                    nil,
+	}
+
+	for pattern, expected := range cases {
+		re, err := syntax.Parse("(?i)"+pattern, syntax.Perl)
+		if err != nil {
+			t.Fatalf("invalid pattern %v: %v", pattern, err)
+		}
+
+		if literals := requiredLiterals(re.Simplify()); !reflect.DeepEqual(literals, expected) {
+			t.Fatalf("%v: expected %q, got %q", pattern, expected, literals)
+		}
+	}
+}
+
+func TestLiteralIndex(t *testing.T) {
+	idx := newLiteralIndex()
+	ids := map[string]int{}
+	for _, lit := range []string{"hers", "she", "his", "he", "kubernetes", "missing"} {
+		ids[lit] = idx.add(lit)
+	}
+	idx.build()
+
+	found := idx.find("uSHErs and Kubernetes")
+	for lit, expected := range map[string]bool{"hers": true, "she": true, "he": true, "his": false, "kubernetes": true, "missing": false} {
+		if found[ids[lit]] != expected {
+			t.Fatalf("%v: expected found %v", lit, expected)
+		}
+	}
+}
+
+func TestPrefilterEqualResults(t *testing.T) {
+	indexed, plain := newPrefilterAnalyzers(t)
+
+	bodies := []string{
+		prefilterPage,
+		strings.ToUpper(prefilterPage),
+		"",
+		"<html><body>nothing to see</body></html>",
+		"<p>\u212Aubernetes \u017Fhopify WORDPRE\u017F\u017F</p>",
+	}
+
+	for _, body := range bodies {
+		job := NewOfflineJob("http://example.com", body, nil)
+
+		a, _ := indexed.Process(job)
+		b, _ := plain.Process(job)
+
+		ja, _ := json.Marshal(a.Matches)
+		jb, _ := json.Marshal(b.Matches)
+		if string(ja) != string(jb) {
+			t.Fatalf("prefilter changed results:\n%s\n%s", ja, jb)
+		}
+
+		// every html regex which matches must be selected by the index
+		found := indexed.htmlIndex.find(body)
+		for name, app := range indexed.appDefs.Apps {
+			for _, r := range app.HTMLRegex {
+				if r.Regexp.MatchString(body) && !r.mayMatch(found) {
+					t.Fatalf("%v: pattern %q matches but was filtered", name, r.Pattern)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkProcessHTML(b *testing.B) {
+	indexed, plain := newPrefilterAnalyzers(b)
+
+	body := strings.Repeat(prefilterPage, 50)
+	job := NewOfflineJob("http://example.com", body, nil)
+
+	for _, bench := range []struct {
+		name string
+		wa   *WebAnalyzer
+	}{{"prefilter", indexed}, {"regex", plain}} {
+		wa := bench.wa
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				wa.Process(job)
+			}
+		})
+	}
+}
+
+// newPrefilterAnalyzers loads the embedded technologies with and without
+// the literal index
+func newPrefilterAnalyzers(tb testing.TB) (*WebAnalyzer, *WebAnalyzer) {
+	indexed, err := NewWebAnalyzerDefault(nil)
+	if err != nil {
+		tb.Fatalf("could not load technologies: %v", err)
+	}
+
+	plain, err := NewWebAnalyzerDefault(nil)
+	if err != nil {
+		tb.Fatalf("could not load technologies: %v", err)
+	}
+	plain.htmlIndex = nil
+
+	// make sure the embedded technologies are indexed at all
+	var filtered int
+	for _, app := range indexed.appDefs.Apps {
+		for _, r := range app.HTMLRegex {
+			if _, ok := r.Regexp.(*regexp.Regexp); ok && r.literals != nil {
+				filtered++
+			}
+		}
+	}
+	if filtered == 0 {
+		tb.Fatalf("no html pattern was indexed")
+	}
+
+	return indexed, plain
+}
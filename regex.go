@@ -0,0 +1,220 @@
+package webanalyze
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+)
+
+// Regexp is the subset of *regexp.Regexp used for matching. Patterns which
+// Go's RE2 based regexp package rejects can be compiled by a RegexpEngine
+// into another implementation of this interface.
+type Regexp interface {
+	FindAllStringSubmatch(s string, n int) [][]string
+	MatchString(s string) bool
+	String() string
+}
+
+// RegexpEngine compiles patterns which are not supported by Go's regexp
+// package, e.g. lookaheads or backreferences
+type RegexpEngine interface {
+	Compile(pattern string) (Regexp, error)
+}
+
+// DefaultRegexpEngine is the fallback engine used when loading app
+// definitions. Set it to nil to drop unsupported patterns instead.
+var DefaultRegexpEngine RegexpEngine = &BacktrackingEngine{Timeout: 100 * time.Millisecond}
+
+// Ways a pattern can be degraded while compiling, see DegradedPattern
+const (
+	PatternTranslated = "translated"
+	PatternFallback   = "fallback"
+	PatternDropped    = "dropped"
+)
+
+// DegradedPattern describes a pattern of an app which could not be
+// compiled by Go's regexp package as is
+type DegradedPattern struct {
+	App     string `json:"app"`
+	Field   string `json:"field"`
+	Key     string `json:"key,omitempty"`
+	Pattern string `json:"pattern"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// patternCompiler compiles the patterns of a single app and records
+// the ones which had to be translated, handed to the fallback engine
+// or dropped
+type patternCompiler struct {
+	app      string
+	fallback RegexpEngine
+	degraded []DegradedPattern
+}
+
+// compile compiles a case insensitive pattern, trying Go's regexp package
+// first, then a translation of common PCRE constructs and finally the
+// fallback engine
+func (pc *patternCompiler) compile(field, key, pattern string) (Regexp, error) {
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err == nil {
+		return re, nil
+	}
+
+	d := DegradedPattern{
+		App:     pc.app,
+		Field:   field,
+		Key:     key,
+		Pattern: pattern,
+	}
+
+	if translated, ok := translatePCRE(pattern); ok {
+		if re, terr := regexp.Compile("(?i)" + translated); terr == nil {
+			d.Status = PatternTranslated
+			pc.degraded = append(pc.degraded, d)
+			return re, nil
+		}
+	}
+
+	if pc.fallback != nil {
+		if re, ferr := pc.fallback.Compile("(?i)" + pattern); ferr == nil {
+			d.Status = PatternFallback
+			pc.degraded = append(pc.degraded, d)
+			return re, nil
+		}
+	}
+
+	d.Status = PatternDropped
+	d.Error = err.Error()
+	pc.degraded = append(pc.degraded, d)
+	return nil, err
+}
+
+// translatePCRE rewrites PCRE constructs which have an RE2 equivalent
+// (or a close approximation): atomic groups, possessive quantifiers and
+// the \Z and \h escapes. The boolean reports whether anything changed.
+func translatePCRE(pattern string) (string, bool) {
+	var b strings.Builder
+	inClass := false
+	changed := false
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			switch next := pattern[i]; {
+			case next == 'Z' && !inClass:
+				b.WriteString(`\z`)
+				changed = true
+			case next == 'h':
+				if inClass {
+					b.WriteString(` \t`)
+				} else {
+					b.WriteString(`[ \t]`)
+				}
+				changed = true
+			default:
+				b.WriteByte(c)
+				b.WriteByte(next)
+			}
+			continue
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+			// a leading ] (or ^]) is part of the class
+			if strings.HasPrefix(pattern[i+1:], "]") {
+				b.WriteString("[]")
+				i++
+				continue
+			} else if strings.HasPrefix(pattern[i+1:], "^]") {
+				b.WriteString("[^]")
+				i += 2
+				continue
+			}
+		case c == '(' && strings.HasPrefix(pattern[i+1:], "?>"):
+			b.WriteString("(?:")
+			i += 2
+			changed = true
+			continue
+		case (c == '*' || c == '+' || c == '?' || c == '}') && i+1 < len(pattern) && pattern[i+1] == '+':
+			// possessive quantifier, RE2 never backtracks into it anyway
+			b.WriteByte(c)
+			i++
+			changed = true
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String(), changed
+}
+
+// BacktrackingEngine is a RegexpEngine based on a pure Go backtracking
+// implementation supporting the .NET/PCRE syntax. As backtracking can
+// be exponential, every match is limited by Timeout.
+type BacktrackingEngine struct {
+	Timeout time.Duration
+}
+
+// Compile implements RegexpEngine
+func (e *BacktrackingEngine) Compile(pattern string) (Regexp, error) {
+	re, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+	if e.Timeout > 0 {
+		re.MatchTimeout = e.Timeout
+	}
+	return &backtrackingRegexp{re}, nil
+}
+
+type backtrackingRegexp struct {
+	re *regexp2.Regexp
+}
+
+func (r *backtrackingRegexp) FindAllStringSubmatch(s string, n int) [][]string {
+	var matches [][]string
+
+	m, err := r.re.FindStringMatch(s)
+	for m != nil && err == nil && (n < 0 || len(matches) < n) {
+		groups := m.Groups()
+		submatches := make([]string, len(groups))
+		for i, g := range groups {
+			submatches[i] = g.String()
+		}
+		matches = append(matches, submatches)
+
+		m, err = r.re.FindNextMatch(m)
+	}
+
+	return matches
+}
+
+func (r *backtrackingRegexp) MatchString(s string) bool {
+	ok, err := r.re.MatchString(s)
+	return ok && err == nil
+}
+
+func (r *backtrackingRegexp) String() string {
+	return r.re.String()
+}
+
+// numSubexp returns the number of capture groups of a compiled pattern or
+// -1 if the engine does not expose it
+func numSubexp(re Regexp) int {
+	switch r := re.(type) {
+	case *regexp.Regexp:
+		return r.NumSubexp()
+	case *backtrackingRegexp:
+		return len(r.re.GetGroupNumbers()) - 1
+	}
+	return -1
+}
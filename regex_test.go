@@ -0,0 +1,62 @@
+package webanalyze
+
+import (
+	"testing"
+)
+
+func TestTranslatePCRE(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		expected string
+		changed  bool
+	}{
+		{`foo(?>bar|baz)`, `foo(?:bar|baz)`, true},
+		{`a++b*+c?+d{2}+`, `a+b*c?d{2}`, true},
+		{`end\Z`, `end\z`, true},
+		{`a\hb[\h,]`, `a[ \t]b[ \t,]`, true},
+		{`[+]+ \++`, `[+]+ \++`, false},
+		{`[]++]`, `[]++]`, false},
+	}
+
+	for _, c := range cases {
+		translated, changed := translatePCRE(c.pattern)
+		if translated != c.expected || changed != c.changed {
+			t.Fatalf("%v: expected %v (%v), got %v (%v)", c.pattern, c.expected, c.changed, translated, changed)
+		}
+	}
+}
+
+func TestPatternCompilerFallback(t *testing.T) {
+	pc := &patternCompiler{app: "Test", fallback: DefaultRegexpEngine}
+
+	regexes := pc.compileRegexes("html", StringArray{
+		`foo(?!bar)([\d.]+)`,
+		`(?>atomic)`,
+		`(unbalanced`,
+		`plain`,
+	})
+
+	if len(regexes) != 3 {
+		t.Fatalf("expected 3 compiled patterns, got %v", len(regexes))
+	}
+
+	m := regexes[0].Regexp.FindAllStringSubmatch("foobar FOO1.2", -1)
+	if len(m) != 1 || m[0][1] != "1.2" {
+		t.Fatalf("fallback engine returned invalid submatches: %v", m)
+	}
+
+	expected := map[string]string{
+		`foo(?!bar)([\d.]+)`: PatternFallback,
+		`(?>atomic)`:         PatternTranslated,
+		`(unbalanced`:        PatternDropped,
+	}
+
+	if len(pc.degraded) != len(expected) {
+		t.Fatalf("expected %v degraded patterns, got %v", len(expected), len(pc.degraded))
+	}
+	for _, d := range pc.degraded {
+		if d.Status != expected[d.Pattern] || d.App != "Test" || d.Field != "html" {
+			t.Fatalf("invalid degraded pattern: %+v", d)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package webanalyze
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxRobotsSize limits the number of bytes read from a robots.txt
+const maxRobotsSize = 512 << 10
+
+// robotsCacheSize and robotsCacheTTL bound the robots.txt files kept of
+// scanned origins
+const (
+	robotsCacheSize = 10000
+	robotsCacheTTL  = time.Hour
+)
+
+// robotsTxt returns the robots.txt of the origin of rawURL, or an empty
+// string if it is not enabled or can not be retrieved. Missing files are
+// cached like responses, failed requests are retried by the next job of
+// the origin.
+func (wa *WebAnalyzer) robotsTxt(ctx context.Context, rawURL string) string {
+	if !wa.fetchRobots || !wa.hasRobots {
+		return ""
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	origin := u.Scheme + "://" + u.Host
+	body := wa.robots.get(origin, func() (interface{}, bool) {
+		resp, err := wa.fetch(ctx, origin+"/robots.txt")
+		if err != nil {
+			return "", false
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+		case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+			// a missing robots.txt is not an error of the scan
+			return "", true
+		default:
+			return "", false
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+		if err != nil {
+			return "", false
+		}
+		return string(body), true
+	})
+
+	return body.(string)
+}
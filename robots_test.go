@@ -0,0 +1,104 @@
+package webanalyze
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestProcessRobots(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			atomic.AddInt32(&requests, 1)
+			fmt.Fprint(w, "# robots.txt generated by Shopbuilder 2.4.1\nUser-agent: *\nDisallow: /checkout/\n")
+			return
+		}
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer srv.Close()
+
+	defs := `{"categories": {}, "technologies": {
+		"Shopbuilder": {"robots": ["generated by Shopbuilder ([\\d.]+)\\;version:\\1", "Disallow: /checkout/\\;confidence:50"]},
+		"Magento": {"robots": "Disallow: /catalogsearch/"}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithRobots(true))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	for _, path := range []string{"/", "/products/1"} {
+		result, _ := wa.Process(NewOnlineJob(srv.URL+path, "", nil, 0, false, false))
+		if result.Error != nil {
+			t.Fatalf("%v: process failed: %v", path, result.Error)
+		}
+
+		if len(result.Matches) != 1 || result.Matches[0].AppName != "Shopbuilder" {
+			t.Fatalf("%v: expected robots match, got %v", path, matchNames(result.Matches))
+		}
+
+		m := result.Matches[0]
+		if m.Version != "2.4.1" || m.Confidence != 100 || len(m.Evidence) != 2 {
+			t.Fatalf("%v: unexpected match %+v", path, m)
+		}
+		if e := m.Evidence[0]; e.Source != SourceRobots || e.Version != "2.4.1" {
+			t.Fatalf("%v: unexpected evidence %+v", path, e)
+		}
+	}
+
+	// both jobs share an origin, robots.txt is fetched once
+	if atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("expected a single robots.txt request, got %v", atomic.LoadInt32(&requests))
+	}
+
+	wa, err = NewWebAnalyzerWithOptions(strings.NewReader(defs))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+	if len(result.Matches) != 0 || atomic.LoadInt32(&requests) != 1 {
+		t.Fatalf("robots.txt fetched although disabled")
+	}
+}
+
+func TestProcessRobotsFailure(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			fmt.Fprint(w, "<html></html>")
+			return
+		}
+
+		// the first request fails temporarily
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "Disallow: /catalogsearch/\n")
+	}))
+	defer srv.Close()
+
+	defs := `{"categories": {}, "technologies": {"Magento": {"robots": "Disallow: /catalogsearch/"}}}`
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithRobots(true))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	expected := []int{0, 1, 1}
+	for i, n := range expected {
+		result, _ := wa.Process(NewOnlineJob(srv.URL, "", nil, 0, false, false))
+		if len(result.Matches) != n {
+			t.Fatalf("job %v: expected %v matches, got %v", i, n, matchNames(result.Matches))
+		}
+	}
+
+	// the failed response is not cached, the successful one is
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("expected 2 robots.txt requests, got %v", n)
+	}
+}
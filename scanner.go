@@ -0,0 +1,163 @@
+package webanalyze
+
+import (
+	"context"
+	"net/url"
+	"sync"
+)
+
+// Scanner processes jobs on a pool of workers. Links discovered while
+// crawling are fed back into its queue, unless they were already scanned
+// or the maximum crawl depth is reached.
+type Scanner struct {
+	// Workers is the number of jobs processed concurrently
+	Workers int
+
+	// MaxDepth limits how many links deep crawling follows from the
+	// initial jobs. Zero disables following discovered links.
+	MaxDepth int
+
+	wa        *WebAnalyzer
+	scheduler chan *Job
+}
+
+// NewScanner returns a scanner for wa with the given number of workers,
+// following discovered links one level deep
+func NewScanner(wa *WebAnalyzer, workers int) *Scanner {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Scanner{
+		Workers:  workers,
+		MaxDepth: 1,
+		wa:       wa,
+	}
+}
+
+// Run processes all jobs received on jobs and emits their results. The
+// returned channel is closed once jobs is closed and all queued jobs,
+// including the ones discovered by crawling, are processed, or as soon
+// as ctx is done. The results channel must be drained by the caller.
+func (s *Scanner) Run(ctx context.Context, jobs <-chan *Job) <-chan Result {
+	results := make(chan Result)
+	done := make(chan []*Job)
+	s.scheduler = make(chan *Job)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx, results, done)
+		}()
+	}
+
+	go func() {
+		s.dispatch(ctx, jobs, done)
+
+		close(s.scheduler)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// dispatch queues incoming and discovered jobs and hands them to the
+// workers until there is no more work or ctx is done
+func (s *Scanner) dispatch(ctx context.Context, jobs <-chan *Job, done <-chan []*Job) {
+	var queue []*Job
+	seen := make(map[string]bool)
+	pending := 0
+
+	enqueue := func(job *Job) {
+		key := jobKey(job.URL)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		queue = append(queue, job)
+		pending++
+	}
+
+	for jobs != nil || pending > 0 {
+		var next *Job
+		var scheduler chan *Job
+		if len(queue) > 0 {
+			next = queue[0]
+			scheduler = s.scheduler
+		}
+
+		select {
+		case job, ok := <-jobs:
+			if !ok {
+				jobs = nil
+				continue
+			}
+			enqueue(job)
+		case scheduler <- next:
+			queue = queue[1:]
+		case discovered := <-done:
+			pending--
+			for _, job := range discovered {
+				enqueue(job)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// work processes scheduled jobs and reports discovered links back to the
+// dispatcher
+func (s *Scanner) work(ctx context.Context, results chan<- Result, done chan<- []*Job) {
+	for job := range s.scheduler {
+		result, links := s.wa.ProcessContext(ctx, job)
+
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+
+		select {
+		case done <- s.crawlJobs(job, links):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// crawlJobs creates the jobs for links discovered while processing parent
+func (s *Scanner) crawlJobs(parent *Job, links []string) []*Job {
+	if parent.depth >= s.MaxDepth {
+		return nil
+	}
+
+	// only extract links on pages whose links will be followed
+	crawl := parent.Crawl
+	if parent.depth+1 >= s.MaxDepth {
+		crawl = 0
+	}
+
+	var jobs []*Job
+	for _, link := range links {
+		job := NewOnlineJob(link, "", nil, crawl, parent.SearchSubdomain, parent.followRedirect)
+		job.FetchScripts = parent.FetchScripts
+		job.depth = parent.depth + 1
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// jobKey normalizes a job url for deduplication
+func jobKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	u.Fragment = ""
+	return u.String()
+}
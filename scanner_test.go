@@ -0,0 +1,60 @@
+package webanalyze
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScannerCrawl(t *testing.T) {
+	pages := map[string]string{
+		"/":  `<a href="/a">a</a><a href="/b">b</a><a href="/a#top">a</a>`,
+		"/a": `<a href="/c">c</a> foo`,
+		"/b": `<a href="/">root</a>`,
+		"/c": `foo`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, pages[r.URL.Path])
+	}))
+	defer srv.Close()
+
+	wa := newTestAnalyzer(t, `{"Foo": {"cats": [1], "html": "foo"}}`)
+
+	jobs := make(chan *Job, 2)
+	jobs <- NewOnlineJob(srv.URL+"/", "", nil, 10, false, false)
+	jobs <- NewOnlineJob(srv.URL+"/", "", nil, 10, false, false)
+	close(jobs)
+
+	scanned := make(map[string]int)
+	for result := range NewScanner(wa, 2).Run(context.Background(), jobs) {
+		if result.Error != nil {
+			t.Fatalf("%v failed: %v", result.Host, result.Error)
+		}
+		scanned[result.Host]++
+	}
+
+	expected := []string{srv.URL + "/", srv.URL + "/a", srv.URL + "/b"}
+	if len(scanned) != len(expected) {
+		t.Fatalf("expected %v results, got %v", len(expected), scanned)
+	}
+	for _, u := range expected {
+		if scanned[u] != 1 {
+			t.Fatalf("%v scanned %v times", u, scanned[u])
+		}
+	}
+}
+
+func TestScannerCanceled(t *testing.T) {
+	wa := newTestAnalyzer(t, `{"Foo": {"cats": [1], "html": "foo"}}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the input channel is never closed, the scanner must still shut down
+	jobs := make(chan *Job)
+	for range NewScanner(wa, 2).Run(ctx, jobs) {
+	}
+}
@@ -0,0 +1,110 @@
+package webanalyze
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// backReference matches \1 style references in version templates
+var backReference = regexp.MustCompile(`\\(\d+)`)
+
+// findVersion evaluates a version template against all matches and
+// returns the highest resulting version
+func findVersion(matches [][]string, template string) string {
+	var best string
+
+	for _, groups := range matches {
+		if v := resolveVersion(template, groups); betterVersion(v, best) {
+			best = v
+		}
+	}
+
+	return best
+}
+
+// resolveVersion evaluates a version template like "\1", "v\1.\2" or
+// "\1?4.x:3.x" against the submatches of a single regex match. Ternaries
+// resolve to their first branch if the referenced group is not empty.
+func resolveVersion(template string, groups []string) string {
+	version := template
+
+	// resolve higher references first, so that \1 does not clobber \10
+	for i := len(groups) - 1; i >= 1; i-- {
+		ref := "\\" + strconv.Itoa(i)
+		if !strings.Contains(version, ref) {
+			continue
+		}
+
+		// a ternary \N?a:b spans the rest of the template
+		if j := strings.Index(version, ref+"?"); j >= 0 {
+			rest := version[j+len(ref)+1:]
+			if k := strings.IndexByte(rest, ':'); k > 0 {
+				branch := rest[k+1:]
+				if groups[i] != "" {
+					branch = rest[:k]
+				}
+				version = version[:j] + branch
+			}
+		}
+
+		version = strings.TrimSpace(version)
+		version = strings.ReplaceAll(version, ref, groups[i])
+	}
+
+	// references to groups which do not exist resolve to nothing
+	version = backReference.ReplaceAllString(version, "")
+
+	return strings.TrimSpace(version)
+}
+
+// betterVersion reports whether version a should be preferred over b,
+// which is the case if it is higher or more specific
+func betterVersion(a, b string) bool {
+	if a == "" {
+		return false
+	}
+	if b == "" {
+		return true
+	}
+	return compareVersions(a, b) > 0
+}
+
+// compareVersions compares two version strings segment by segment.
+// Numeric segments are compared by value, others lexically. If one
+// version is a prefix of the other, the longer one is considered higher.
+func compareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+	}
+
+	as, bs := split(a), split(b)
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+
+		switch {
+		case aerr == nil && berr == nil:
+			if an != bn {
+				if an > bn {
+					return 1
+				}
+				return -1
+			}
+		case as[i] != bs[i]:
+			return strings.Compare(as[i], bs[i])
+		}
+	}
+
+	switch {
+	case len(as) > len(bs):
+		return 1
+	case len(as) < len(bs):
+		return -1
+	}
+	return 0
+}
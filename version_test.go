@@ -0,0 +1,38 @@
+package webanalyze
+
+import (
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	cases := []struct {
+		template string
+		groups   []string
+		expected string
+	}{
+		{`\1`, []string{"foo-1.2", "1.2"}, "1.2"},
+		{`\1.\2`, []string{"", "3", "4"}, "3.4"},
+		{`\1?4.x:3.x`, []string{"", "x"}, "4.x"},
+		{`\1?4.x:3.x`, []string{"", ""}, "3.x"},
+		{`\2?\2:\1`, []string{"", "1.0", ""}, "1.0"},
+		{`\11`, []string{"", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "11.0"}, "11.0"},
+		{`\4`, []string{"", "1"}, ""},
+		{`2.x`, []string{""}, "2.x"},
+		{`v\1?a:b`, []string{"", "x"}, "va"},
+		{`\1?\2:b`, []string{"", "x", "1.2"}, "1.2"},
+	}
+
+	for _, c := range cases {
+		if v := resolveVersion(c.template, c.groups); v != c.expected {
+			t.Fatalf("%v: expected %q, got %q", c.template, c.expected, v)
+		}
+	}
+}
+
+func TestFindVersionHighest(t *testing.T) {
+	matches := [][]string{{"", "1.9"}, {"", "1.10"}, {"", "1.10.2"}, {"", "1.2"}}
+
+	if v := findVersion(matches, `\1`); v != "1.10.2" {
+		t.Fatalf("expected highest version 1.10.2, got %v", v)
+	}
+}
@@ -0,0 +1,382 @@
+package webanalyze
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Vulnerability is a CVE affecting the detected version of an app
+type Vulnerability struct {
+	ID       string  `json:"id"`
+	Score    float64 `json:"cvss,omitempty"` // highest CVSS base score
+	Severity string  `json:"severity,omitempty"`
+}
+
+// VulnDB matches CPEs against the vulnerabilities of locally downloaded
+// NVD feeds, so no requests are made while scanning. Configurations which
+// combine several CPEs (e.g. an app running on a specific OS) are matched
+// by the vulnerable CPE alone.
+type VulnDB struct {
+	// cpe matches indexed by vendor and product
+	matches map[string][]cpeMatch
+}
+
+// cpeMatch is a vulnerable CPE of a CVE, optionally with a version range
+type cpeMatch struct {
+	vuln           Vulnerability
+	version        string
+	startIncluding string
+	startExcluding string
+	endIncluding   string
+	endExcluding   string
+}
+
+// NewVulnDB returns an empty vulnerability database, see Load
+func NewVulnDB() *VulnDB {
+	return &VulnDB{matches: make(map[string][]cpeMatch)}
+}
+
+// nvdFeed covers the NVD JSON 1.1 data feeds and the responses of the
+// NVD CVE API 2.0
+type nvdFeed struct {
+	Items []struct {
+		CVE struct {
+			Meta struct {
+				ID string `json:"ID"`
+			} `json:"CVE_data_meta"`
+		} `json:"cve"`
+		Configurations struct {
+			Nodes []nvdNode `json:"nodes"`
+		} `json:"configurations"`
+		Impact struct {
+			V3 struct {
+				CVSS nvdCVSS `json:"cvssV3"`
+			} `json:"baseMetricV3"`
+			V2 struct {
+				CVSS     nvdCVSS `json:"cvssV2"`
+				Severity string  `json:"severity"`
+			} `json:"baseMetricV2"`
+		} `json:"impact"`
+	} `json:"CVE_Items"`
+
+	Vulnerabilities []struct {
+		CVE struct {
+			ID             string `json:"id"`
+			Configurations []struct {
+				Nodes []nvdNode `json:"nodes"`
+			} `json:"configurations"`
+			Metrics map[string][]struct {
+				CVSS         nvdCVSS `json:"cvssData"`
+				BaseSeverity string  `json:"baseSeverity"`
+			} `json:"metrics"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+type nvdNode struct {
+	Children []nvdNode     `json:"children"`
+	Matches  []nvdCPEMatch `json:"cpe_match"`
+	Matches2 []nvdCPEMatch `json:"cpeMatch"`
+}
+
+type nvdCPEMatch struct {
+	Vulnerable     bool   `json:"vulnerable"`
+	URI            string `json:"cpe23Uri"`
+	Criteria       string `json:"criteria"`
+	StartIncluding string `json:"versionStartIncluding"`
+	StartExcluding string `json:"versionStartExcluding"`
+	EndIncluding   string `json:"versionEndIncluding"`
+	EndExcluding   string `json:"versionEndExcluding"`
+}
+
+type nvdCVSS struct {
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+}
+
+// Load adds the vulnerabilities of an NVD JSON feed (1.1 data feed or CVE
+// API 2.0 response), which may be gzip compressed
+func (db *VulnDB) Load(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	var feed nvdFeed
+	if err := json.NewDecoder(r).Decode(&feed); err != nil {
+		return fmt.Errorf("invalid nvd feed: %w", err)
+	}
+
+	for _, item := range feed.Items {
+		vuln := Vulnerability{ID: item.CVE.Meta.ID}
+		switch {
+		case item.Impact.V3.CVSS.BaseScore > 0:
+			vuln.Score = item.Impact.V3.CVSS.BaseScore
+			vuln.Severity = item.Impact.V3.CVSS.BaseSeverity
+		case item.Impact.V2.CVSS.BaseScore > 0:
+			vuln.Score = item.Impact.V2.CVSS.BaseScore
+			vuln.Severity = item.Impact.V2.Severity
+		}
+
+		db.addNodes(vuln, item.Configurations.Nodes)
+	}
+
+	for _, item := range feed.Vulnerabilities {
+		vuln := Vulnerability{ID: item.CVE.ID}
+		for _, metrics := range item.CVE.Metrics {
+			for _, m := range metrics {
+				if m.CVSS.BaseScore > vuln.Score {
+					vuln.Score = m.CVSS.BaseScore
+					vuln.Severity = m.CVSS.BaseSeverity
+					if vuln.Severity == "" {
+						vuln.Severity = m.BaseSeverity
+					}
+				}
+			}
+		}
+
+		for _, config := range item.CVE.Configurations {
+			db.addNodes(vuln, config.Nodes)
+		}
+	}
+
+	return nil
+}
+
+func (db *VulnDB) addNodes(vuln Vulnerability, nodes []nvdNode) {
+	for _, node := range nodes {
+		db.addNodes(vuln, node.Children)
+
+		for _, m := range append(node.Matches, node.Matches2...) {
+			uri := m.Criteria
+			if uri == "" {
+				uri = m.URI
+			}
+
+			fields := splitCPE(uri)
+			if !m.Vulnerable || len(fields) <= cpeVersionField {
+				continue
+			}
+
+			key := cpeProduct(fields)
+			db.matches[key] = append(db.matches[key], cpeMatch{
+				vuln:           vuln,
+				version:        unescapeCPE(fields[cpeVersionField]),
+				startIncluding: m.StartIncluding,
+				startExcluding: m.StartExcluding,
+				endIncluding:   m.EndIncluding,
+				endExcluding:   m.EndExcluding,
+			})
+		}
+	}
+}
+
+// Lookup returns the vulnerabilities affecting the version of a CPE 2.3
+// formatted string, sorted by descending score. CPEs without a version
+// never match.
+func (db *VulnDB) Lookup(cpe string) []Vulnerability {
+	fields := splitCPE(cpe)
+	if len(fields) <= cpeVersionField {
+		return nil
+	}
+
+	version := unescapeCPE(fields[cpeVersionField])
+	if version == "*" || version == "-" || version == "" {
+		return nil
+	}
+
+	var vulns []Vulnerability
+	seen := make(map[string]bool)
+	for _, m := range db.matches[cpeProduct(fields)] {
+		if seen[m.vuln.ID] || !m.affects(version) {
+			continue
+		}
+		seen[m.vuln.ID] = true
+		vulns = append(vulns, m.vuln)
+	}
+
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].Score != vulns[j].Score {
+			return vulns[i].Score > vulns[j].Score
+		}
+		return vulns[i].ID < vulns[j].ID
+	})
+	return vulns
+}
+
+// affects reports whether version is in the range of the cpe match.
+// Partial versions like 4.x are affected if any release they cover is.
+func (m cpeMatch) affects(version string) bool {
+	v := parseRangeVersion(version)
+
+	switch m.version {
+	case "-":
+		return false
+	case "*", "":
+	default:
+		exact := parseRangeVersion(m.version)
+		return v.compare(exact, false) <= 0 && v.compare(exact, true) >= 0
+	}
+
+	// the highest covered release must reach the start of the range and
+	// the lowest one must be below its end
+	switch {
+	case m.startIncluding != "" && v.compare(parseRangeVersion(m.startIncluding), true) < 0,
+		m.startExcluding != "" && v.compare(parseRangeVersion(m.startExcluding), true) <= 0,
+		m.endIncluding != "" && v.compare(parseRangeVersion(m.endIncluding), false) > 0,
+		m.endExcluding != "" && v.compare(parseRangeVersion(m.endExcluding), false) >= 0:
+		return false
+	}
+
+	// a wildcard version without range affects all versions
+	return true
+}
+
+// preReleases ranks the pre-release markers of versions, which sort
+// before the release itself (5.0.0-rc1 < 5.0.0)
+var preReleases = map[string]int{
+	"dev": 1, "snapshot": 1, "alpha": 2, "a": 2, "beta": 3, "b": 3, "pre": 4, "preview": 4, "rc": 5,
+}
+
+// rangeVersion is a version split into numeric and alphabetic segments,
+// e.g. 5.0.0-rc1 into 5, 0, 0, rc, 1. A trailing x or * segment marks a
+// partial version.
+type rangeVersion struct {
+	segments []string
+	partial  bool
+}
+
+func parseRangeVersion(version string) rangeVersion {
+	var v rangeVersion
+
+	var segment []rune
+	var digits bool
+	flush := func() {
+		if len(segment) > 0 {
+			v.segments = append(v.segments, string(segment))
+			segment = nil
+		}
+	}
+
+	for _, r := range strings.ToLower(unescapeCPE(version)) {
+		isDigit, isLetter := unicode.IsDigit(r), unicode.IsLetter(r)
+		if !isDigit && !isLetter && r != '*' {
+			flush()
+			continue
+		}
+		if len(segment) > 0 && isDigit != digits {
+			flush()
+		}
+		segment = append(segment, r)
+		digits = isDigit
+	}
+	flush()
+
+	for i, s := range v.segments {
+		if s == "x" || s == "*" {
+			v.segments, v.partial = v.segments[:i], true
+			break
+		}
+	}
+	return v
+}
+
+// compare compares v with bound. For partial versions high selects the
+// highest instead of the lowest release covered by v.
+func (v rangeVersion) compare(bound rangeVersion, high bool) int {
+	for i := 0; ; i++ {
+		switch {
+		case i == len(v.segments) && v.partial && high:
+			return 1
+		case i == len(v.segments):
+			return -tailSign(bound.segments[i:])
+		case i == len(bound.segments):
+			return tailSign(v.segments[i:])
+		}
+
+		if c := compareSegments(v.segments[i], bound.segments[i]); c != 0 {
+			return c
+		}
+	}
+}
+
+// tailSign returns whether the remaining segments of the longer version
+// make it higher (1.0.1), lower (1.0-rc1) or equal (1.0.0)
+func tailSign(rest []string) int {
+	for _, s := range rest {
+		if n, err := strconv.Atoi(s); err == nil && n == 0 {
+			continue
+		}
+		if _, ok := preReleases[s]; ok {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func compareSegments(a, b string) int {
+	an, aerr := strconv.Atoi(a)
+	bn, berr := strconv.Atoi(b)
+	ar, apre := preReleases[a]
+	br, bpre := preReleases[b]
+
+	switch {
+	case aerr == nil && berr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case apre && bpre:
+		switch {
+		case ar < br:
+			return -1
+		case ar > br:
+			return 1
+		}
+		return 0
+	case apre:
+		return -1
+	case bpre:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// cpeProduct returns the part, vendor and product of a split CPE
+func cpeProduct(fields []string) string {
+	return strings.ToLower(strings.Join(fields[2:cpeVersionField], ":"))
+}
+
+// unescapeCPE removes the quoting of a CPE 2.3 formatted field
+func unescapeCPE(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,152 @@
+package webanalyze
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+const nvdFeed11 = `{"CVE_Items": [
+	{
+		"cve": {"CVE_data_meta": {"ID": "CVE-2023-0001"}},
+		"configurations": {"nodes": [{"operator": "OR", "cpe_match": [
+			{"vulnerable": true, "cpe23Uri": "cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*", "versionStartIncluding": "6.0", "versionEndExcluding": "6.1.2"}
+		]}]},
+		"impact": {"baseMetricV3": {"cvssV3": {"baseScore": 6.1, "baseSeverity": "MEDIUM"}}}
+	},
+	{
+		"cve": {"CVE_data_meta": {"ID": "CVE-2023-0002"}},
+		"configurations": {"nodes": [{"operator": "AND", "children": [{"cpe_match": [
+			{"vulnerable": true, "cpe23Uri": "cpe:2.3:a:wordpress:wordpress:6.1:*:*:*:*:*:*:*"},
+			{"vulnerable": false, "cpe23Uri": "cpe:2.3:o:linux:linux_kernel:*:*:*:*:*:*:*:*"}
+		]}]}]},
+		"impact": {"baseMetricV2": {"cvssV2": {"baseScore": 5.0}, "severity": "MEDIUM"}}
+	},
+	{
+		"cve": {"CVE_data_meta": {"ID": "CVE-2023-0003"}},
+		"configurations": {"nodes": [{"cpe_match": [
+			{"vulnerable": true, "cpe23Uri": "cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*", "versionEndIncluding": "5.9"}
+		]}]}
+	}
+]}`
+
+const nvdAPI20 = `{"vulnerabilities": [{"cve": {
+	"id": "CVE-2024-0001",
+	"configurations": [{"nodes": [{"operator": "OR", "cpeMatch": [
+		{"vulnerable": true, "criteria": "cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*", "versionStartIncluding": "1.25.0", "versionEndIncluding": "1.25.3"}
+	]}]}],
+	"metrics": {
+		"cvssMetricV31": [{"cvssData": {"baseScore": 7.5, "baseSeverity": "HIGH"}}],
+		"cvssMetricV2": [{"cvssData": {"baseScore": 5.0}, "baseSeverity": "MEDIUM"}]
+	}
+}}]}`
+
+func newTestVulnDB(t *testing.T) *VulnDB {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(nvdAPI20))
+	w.Close()
+
+	db := NewVulnDB()
+	for _, feed := range []*bytes.Reader{bytes.NewReader([]byte(nvdFeed11)), bytes.NewReader(gz.Bytes())} {
+		if err := db.Load(feed); err != nil {
+			t.Fatalf("could not load feed: %v", err)
+		}
+	}
+	return db
+}
+
+func TestVulnDBLookup(t *testing.T) {
+	db := newTestVulnDB(t)
+
+	cases := map[string]string{
+		"cpe:2.3:a:wordpress:wordpress:6.1:*:*:*:*:*:*:*":   "CVE-2023-0001,CVE-2023-0002",
+		"cpe:2.3:a:wordpress:wordpress:6.1.0:*:*:*:*:*:*:*": "CVE-2023-0001,CVE-2023-0002",
+		"cpe:2.3:a:wordpress:wordpress:6.1.2:*:*:*:*:*:*:*": "",
+		"cpe:2.3:a:wordpress:wordpress:5.8:*:*:*:*:*:*:*":   "CVE-2023-0003",
+		"cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*":     "",
+		"cpe:2.3:a:f5:nginx:1.25.3:*:*:*:*:*:*:*":           "CVE-2024-0001",
+		"cpe:2.3:a:f5:nginx:1.26.0:*:*:*:*:*:*:*":           "",
+		"cpe:2.3:o:linux:linux_kernel:6.1:*:*:*:*:*:*:*":    "",
+	}
+
+	for cpe, expected := range cases {
+		var ids []string
+		for _, v := range db.Lookup(cpe) {
+			ids = append(ids, v.ID)
+		}
+		if strings.Join(ids, ",") != expected {
+			t.Fatalf("%v: expected %v, got %v", cpe, expected, ids)
+		}
+	}
+
+	vulns := db.Lookup("cpe:2.3:a:f5:nginx:1.25.1:*:*:*:*:*:*:*")
+	if vulns[0].Score != 7.5 || vulns[0].Severity != "HIGH" {
+		t.Fatalf("expected the highest cvss score, got %+v", vulns[0])
+	}
+}
+
+func TestCPEMatchAffects(t *testing.T) {
+	cases := []struct {
+		match    cpeMatch
+		version  string
+		expected bool
+	}{
+		// pre-releases sort before their release
+		{cpeMatch{endExcluding: "5.0.0"}, "5.0.0-rc1", true},
+		{cpeMatch{endExcluding: "5.0.0"}, "5.0.0rc1", true},
+		{cpeMatch{endExcluding: "5.0"}, "5.0.0-beta.2", true},
+		{cpeMatch{endExcluding: "5.0.0"}, "5.0.0", false},
+		{cpeMatch{startIncluding: "5.0.0"}, "5.0.0-alpha", false},
+		{cpeMatch{endIncluding: "5.0.0-rc2"}, "5.0.0-rc1", true},
+		{cpeMatch{endIncluding: "5.0.0-beta"}, "5.0.0-rc1", false},
+		{cpeMatch{endIncluding: "5.0"}, "5.0.0", true},
+		{cpeMatch{endExcluding: "8.9"}, "8.9p1", false},
+
+		// partial versions are affected if a covered release is
+		{cpeMatch{startIncluding: "4.2", endExcluding: "4.5"}, "4.x", true},
+		{cpeMatch{endExcluding: "4.0"}, "4.x", false},
+		{cpeMatch{startIncluding: "5.0"}, "4.x", false},
+		{cpeMatch{endIncluding: "3.9"}, "3.x", true},
+		{cpeMatch{startExcluding: "3.9.9"}, "3.x", true},
+		{cpeMatch{startIncluding: "10.0"}, "1.x", false},
+		{cpeMatch{version: "4.2.1"}, "4.x", true},
+		{cpeMatch{version: "5.0"}, "4.x", false},
+		{cpeMatch{version: "4.2.1"}, "4.2.1", true},
+	}
+
+	for _, c := range cases {
+		if affected := c.match.affects(c.version); affected != c.expected {
+			t.Fatalf("%+v: version %v expected %v, got %v", c.match, c.version, c.expected, affected)
+		}
+	}
+}
+
+func TestProcessVulnerabilities(t *testing.T) {
+	defs := `{"categories": {}, "technologies": {
+		"WordPress": {"meta": {"generator": "^WordPress ([\\d.]+)\\;version:\\1"}, "cpe": "cpe:2.3:a:wordpress:wordpress:*:*:*:*:*:*:*:*"},
+		"Nginx": {"headers": {"Server": "nginx"}, "cpe": "cpe:2.3:a:f5:nginx:*:*:*:*:*:*:*:*"}
+	}}`
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs), WithVulnDB(newTestVulnDB(t)))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+
+	body := `<meta name="generator" content="WordPress 6.1">`
+	result, _ := wa.Process(NewOfflineJob("http://example.com", body, map[string][]string{"Server": {"nginx"}}))
+
+	for _, m := range result.Matches {
+		switch m.AppName {
+		case "WordPress":
+			if len(m.Vulnerabilities) != 2 || m.Vulnerabilities[0].ID != "CVE-2023-0001" {
+				t.Fatalf("expected 2 vulnerabilities sorted by score, got %+v", m.Vulnerabilities)
+			}
+		case "Nginx":
+			if len(m.Vulnerabilities) != 0 {
+				t.Fatalf("match without version has vulnerabilities: %+v", m.Vulnerabilities)
+			}
+		}
+	}
+}
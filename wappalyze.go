@@ -6,7 +6,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -17,23 +18,48 @@ type StringArray []string
 
 // App type encapsulates all the data about an App from technologies.json
 type App struct {
-	Cats     StringArray            `json:"cats"`
-	CatNames []string               `json:"category_names"`
-	Cookies  map[string]string      `json:"cookies"`
-	Headers  map[string]string      `json:"headers"`
-	Meta     map[string]StringArray `json:"meta"`
-	HTML     StringArray            `json:"html"`
-	Script   StringArray            `json:"scripts"`
-	URL      StringArray            `json:"url"`
-	Website  string                 `json:"website"`
-	Implies  StringArray            `json:"implies"`
-
-	HTMLRegex   []AppRegexp `json:"-"`
-	ScriptRegex []AppRegexp `json:"-"`
-	URLRegex    []AppRegexp `json:"-"`
-	HeaderRegex []AppRegexp `json:"-"`
-	MetaRegex   []AppRegexp `json:"-"`
-	CookieRegex []AppRegexp `json:"-"`
+	Cats      StringArray            `json:"cats"`
+	CatNames  []string               `json:"category_names"`
+	Cookies   map[string]string      `json:"cookies"`
+	Headers   map[string]string      `json:"headers"`
+	Meta      map[string]StringArray `json:"meta"`
+	HTML      StringArray            `json:"html"`
+	Script    StringArray            `json:"scripts"`
+	ScriptSrc StringArray            `json:"scriptSrc"`
+	URL       StringArray            `json:"url"`
+	Website   string                 `json:"website"`
+	Implies   StringArray            `json:"implies"`
+	JS        map[string]string      `json:"js"`
+	DOM       DOMSelectors           `json:"dom"`
+
+	CertIssuer StringArray            `json:"certIssuer"`
+	DNS        map[string]StringArray `json:"dns"`
+	Robots     StringArray            `json:"robots"`
+
+	// metadata of the upstream definition, not used for detection
+	CPE         string      `json:"cpe,omitempty"`
+	Icon        string      `json:"icon,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Pricing     StringArray `json:"pricing,omitempty"`
+	SaaS        bool        `json:"saas,omitempty"`
+	OSS         bool        `json:"oss,omitempty"`
+
+	Excludes         StringArray `json:"excludes"`
+	Requires         StringArray `json:"requires"`
+	RequiresCategory StringArray `json:"requiresCategory"`
+
+	HTMLRegex       []AppRegexp  `json:"-"`
+	ScriptRegex     []AppRegexp  `json:"-"`
+	ScriptSrcRegex  []AppRegexp  `json:"-"`
+	URLRegex        []AppRegexp  `json:"-"`
+	HeaderRegex     []AppRegexp  `json:"-"`
+	MetaRegex       []AppRegexp  `json:"-"`
+	CookieRegex     []AppRegexp  `json:"-"`
+	JSRegex         []AppRegexp  `json:"-"`
+	DOMMatchers     []DOMMatcher `json:"-"`
+	CertIssuerRegex []AppRegexp  `json:"-"`
+	DNSRegex        []AppRegexp  `json:"-"`
+	RobotsRegex     []AppRegexp  `json:"-"`
 }
 
 // Category names defined by wappalyzer
@@ -45,17 +71,34 @@ type Category struct {
 type AppsDefinition struct {
 	Apps map[string]App      `json:"technologies"`
 	Cats map[string]Category `json:"categories"`
+
+	names []string // sorted app names
 }
 
 type AppRegexp struct {
-	Name    string
-	Regexp  *regexp.Regexp
-	Version string
+	Name       string
+	Pattern    string // as defined, without version and confidence tags
+	Regexp     Regexp
+	Version    string
+	Confidence int
+
+	literals []int // ids in the literal index, see literalIndex.index
 }
 
+// defaultConfidence is used for patterns without a confidence tag
+const defaultConfidence = 100
+
 func (app *App) FindInHeaders(headers http.Header) (matches [][]string, version string) {
-	var v string
+	for _, e := range app.findInHeaders(headers) {
+		matches = append(matches, e.submatches...)
+		if betterVersion(e.Version, version) {
+			version = e.Version
+		}
+	}
+	return matches, version
+}
 
+func (app *App) findInHeaders(headers http.Header) (evidence []Evidence) {
 	for _, hre := range app.HeaderRegex {
 		if headers.Get(hre.Name) == "" {
 			continue
@@ -65,13 +108,34 @@ func (app *App) FindInHeaders(headers http.Header) (matches [][]string, version
 			if headerValue == "" {
 				continue
 			}
-			if m, version := findMatches(headerValue, []AppRegexp{hre}); len(m) > 0 {
-				matches = append(matches, m...)
-				v = version
-			}
+			evidence = append(evidence, findMatches(headerValue, []AppRegexp{hre}, SourceHeader, hk)...)
+		}
+	}
+	return evidence
+}
+
+// findInJS matches the js regexes of an app against the statically
+// resolved javascript properties of a document
+func (app *App) findInJS(props jsProperties) (evidence []Evidence) {
+	for _, jre := range app.JSRegex {
+		value, ok := props.lookup(jre.Name)
+		if !ok {
+			continue
+		}
+		evidence = append(evidence, findMatches(value, []AppRegexp{jre}, SourceJS, jre.Name)...)
+	}
+	return evidence
+}
+
+// findInMeta matches the meta regexes of an app against the meta tags of
+// a document, see extractMeta
+func (app *App) findInMeta(meta map[string][]string) (evidence []Evidence) {
+	for _, mre := range app.MetaRegex {
+		for _, content := range meta[mre.Name] {
+			evidence = append(evidence, findMatches(content, []AppRegexp{mre}, SourceMeta, mre.Name)...)
 		}
 	}
-	return matches, v
+	return evidence
 }
 
 // UnmarshalJSON is a custom unmarshaler for handling bogus technologies.json types from wappalyzer
@@ -79,9 +143,18 @@ func (t *StringArray) UnmarshalJSON(data []byte) error {
 	var s string
 	var sa []string
 	var na []int
+	var n int
+
+	if string(data) == "null" {
+		return nil
+	}
 
 	if err := json.Unmarshal(data, &s); err != nil {
-		if err := json.Unmarshal(data, &na); err == nil {
+		if err := json.Unmarshal(data, &n); err == nil {
+			// a single number, e.g. a category id
+			*t = StringArray{fmt.Sprintf("%d", n)}
+			return nil
+		} else if err := json.Unmarshal(data, &na); err == nil {
 			// not a string, so maybe []int?
 			*t = make(StringArray, len(na))
 
@@ -95,8 +168,7 @@ func (t *StringArray) UnmarshalJSON(data []byte) error {
 			*t = sa
 			return nil
 		}
-		fmt.Println(string(data))
-		return err
+		return fmt.Errorf("expected string or list, got %s", data)
 	}
 	*t = StringArray{s}
 	return nil
@@ -178,24 +250,25 @@ func (wa *WebAnalyzer) loadApps(r io.Reader) error {
 		return err
 	}
 
-	for key, value := range wa.appDefs.Apps {
+	wa.degraded = nil
+	wa.hasRobots = false
+	wa.htmlIndex = newLiteralIndex()
 
-		app := wa.appDefs.Apps[key]
+	wa.appDefs.names = make([]string, 0, len(wa.appDefs.Apps))
+	for key := range wa.appDefs.Apps {
+		wa.appDefs.names = append(wa.appDefs.names, key)
+	}
+	sort.Strings(wa.appDefs.names)
 
-		app.HTMLRegex = compileRegexes(value.HTML)
-		app.ScriptRegex = compileRegexes(value.Script)
-		app.URLRegex = compileRegexes(value.URL)
+	for _, key := range wa.appDefs.names {
 
-		app.HeaderRegex = compileNamedRegexes(app.Headers)
-		app.CookieRegex = compileNamedRegexes(app.Cookies)
+		app := wa.appDefs.Apps[key]
+		pc := &patternCompiler{app: key, fallback: wa.regexpEngine}
+		pc.compileApp(&app)
 
-		// handle special meta field where value can be a list
-		// of strings. we join them as a simple regex here
-		metaRegex := make(map[string]string)
-		for k, v := range app.Meta {
-			metaRegex[k] = strings.Join(v, "|")
+		for i := range app.HTMLRegex {
+			app.HTMLRegex[i].literals = wa.htmlIndex.index(app.HTMLRegex[i].Regexp)
 		}
-		app.MetaRegex = compileNamedRegexes(metaRegex)
 
 		app.CatNames = make([]string, 0)
 
@@ -205,18 +278,96 @@ func (wa *WebAnalyzer) loadApps(r io.Reader) error {
 			}
 		}
 
+		if len(app.RobotsRegex) > 0 {
+			wa.hasRobots = true
+		}
+
 		wa.appDefs.Apps[key] = app
+		wa.degraded = append(wa.degraded, pc.degraded...)
 
 	}
 
+	wa.htmlIndex.build()
+	wa.dnsTypes = dnsRecordTypes(wa.appDefs.Apps)
+
 	return nil
 }
 
-func compileNamedRegexes(from map[string]string) []AppRegexp {
+// compileApp compiles all patterns of an app definition
+func (pc *patternCompiler) compileApp(app *App) {
+	app.HTMLRegex = pc.compileRegexes("html", app.HTML)
+	app.ScriptRegex = pc.compileRegexes("scripts", app.Script)
+	app.ScriptSrcRegex = pc.compileRegexes("scriptSrc", app.ScriptSrc)
+	app.URLRegex = pc.compileRegexes("url", app.URL)
+	app.CertIssuerRegex = pc.compileRegexes("certIssuer", app.CertIssuer)
+	app.RobotsRegex = pc.compileRegexes("robots", app.Robots)
+
+	app.HeaderRegex = pc.compileNamedRegexes("headers", app.Headers)
+	app.CookieRegex = pc.compileNamedRegexes("cookies", app.Cookies)
+	app.JSRegex = pc.compileNamedRegexes("js", app.JS)
+	app.DOMMatchers = pc.compileDOM(app.DOM)
+
+	// handle special meta and dns fields where values can be a list
+	// of strings, each with its own version and confidence
+	app.MetaRegex = pc.compileNamedLists("meta", app.Meta, strings.ToLower)
+	app.DNSRegex = pc.compileNamedLists("dns", app.DNS, strings.ToUpper)
+}
 
+// compileNamedLists compiles fields which map a name to a list of
+// patterns, the names are normalized by normalize
+func (pc *patternCompiler) compileNamedLists(field string, from map[string]StringArray, normalize func(string) string) []AppRegexp {
 	var list []AppRegexp
 
-	for key, value := range from {
+	names := make([]string, 0, len(from))
+	for k := range from {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		values := from[k]
+		if len(values) == 0 {
+			values = StringArray{""}
+		}
+		for _, v := range values {
+			list = append(list, pc.compileNamedRegexes(field, map[string]string{normalize(k): v})...)
+		}
+	}
+
+	return list
+}
+
+// regexes returns all compiled patterns of an app by field
+func (app *App) regexes() map[string][]AppRegexp {
+	res := map[string][]AppRegexp{
+		"html":       app.HTMLRegex,
+		"scripts":    app.ScriptRegex,
+		"scriptSrc":  app.ScriptSrcRegex,
+		"url":        app.URLRegex,
+		"headers":    app.HeaderRegex,
+		"cookies":    app.CookieRegex,
+		"js":         app.JSRegex,
+		"meta":       app.MetaRegex,
+		"certIssuer": app.CertIssuerRegex,
+		"dns":        app.DNSRegex,
+		"robots":     app.RobotsRegex,
+	}
+
+	for _, dm := range app.DOMMatchers {
+		res["dom"] = append(res["dom"], dm.Text...)
+		res["dom"] = append(res["dom"], dm.Attributes...)
+		res["dom"] = append(res["dom"], dm.Properties...)
+	}
+
+	return res
+}
+
+func (pc *patternCompiler) compileNamedRegexes(field string, from map[string]string) []AppRegexp {
+
+	var list []AppRegexp
+
+	for _, key := range sortedKeys(from) {
+		value := from[key]
 
 		h := AppRegexp{
 			Name: key,
@@ -227,25 +378,24 @@ func compileNamedRegexes(from map[string]string) []AppRegexp {
 		}
 
 		// Filter out webapplyzer attributes from regular expression
-		splitted := strings.Split(value, "\\;")
+		pattern, version, confidence := parsePattern(value)
 
-		r, err := regexp.Compile("(?i)" + splitted[0])
+		r, err := pc.compile(field, key, pattern)
 		if err != nil {
 			continue
 		}
 
-		if len(splitted) > 1 && strings.HasPrefix(splitted[1], "version:") {
-			h.Version = splitted[1][8:]
-		}
-
+		h.Pattern = pattern
 		h.Regexp = r
+		h.Version = version
+		h.Confidence = confidence
 		list = append(list, h)
 	}
 
 	return list
 }
 
-func compileRegexes(s StringArray) []AppRegexp {
+func (pc *patternCompiler) compileRegexes(field string, s StringArray) []AppRegexp {
 	var list []AppRegexp
 
 	for _, regexString := range s {
@@ -255,24 +405,43 @@ func compileRegexes(s StringArray) []AppRegexp {
 		}
 
 		// Split version detection
-		splitted := strings.Split(regexString, "\\;")
+		pattern, version, confidence := parsePattern(regexString)
 
-		regex, err := regexp.Compile("(?i)" + splitted[0])
+		regex, err := pc.compile(field, "", pattern)
 		if err != nil {
-			// ignore failed compiling for now
-			// log.Printf("warning: compiling regexp for failed: %v", regexString, err)
-		} else {
-			rv := AppRegexp{
-				Regexp: regex,
-			}
-
-			if len(splitted) > 1 && strings.HasPrefix(splitted[0], "version") {
-				rv.Version = splitted[1][8:]
-			}
+			// failed patterns are recorded by the compiler, see DegradedPatterns
+			continue
+		}
 
-			list = append(list, rv)
+		rv := AppRegexp{
+			Pattern:    pattern,
+			Regexp:     regex,
+			Version:    version,
+			Confidence: confidence,
 		}
+
+		list = append(list, rv)
 	}
 
 	return list
 }
+
+// parsePattern splits a wappalyzer pattern into the regular expression and
+// its \;version: and \;confidence: tags
+func parsePattern(value string) (pattern, version string, confidence int) {
+	splitted := strings.Split(value, "\\;")
+	confidence = defaultConfidence
+
+	for _, tag := range splitted[1:] {
+		switch {
+		case strings.HasPrefix(tag, "version:"):
+			version = tag[8:]
+		case strings.HasPrefix(tag, "confidence:"):
+			if c, err := strconv.Atoi(tag[11:]); err == nil {
+				confidence = c
+			}
+		}
+	}
+
+	return splitted[0], version, confidence
+}
@@ -2,7 +2,8 @@ package webanalyze
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -17,6 +18,9 @@ import (
 
 const VERSION = "0.3.9"
 
+// maxFetchedScripts limits the number of external scripts downloaded per job
+const maxFetchedScripts = 10
+
 var (
 	timeout = 8 * time.Second
 	wa      *WebAnalyzer
@@ -24,55 +28,166 @@ var (
 
 // Result type encapsulates the result information from a given host
 type Result struct {
-	Host     string        `json:"host"`
-	Matches  []Match       `json:"matches"`
-	Duration time.Duration `json:"duration"`
-	Error    error         `json:"error"`
+	Host      string        `json:"host"`
+	Matches   []Match       `json:"matches"`
+	Duration  time.Duration `json:"duration"`
+	Error     error         `json:"error,omitempty"` // a *ScanError
+	Truncated bool          `json:"truncated"`       // body exceeded the maximum size
+
+	// StatusCode of the response, zero for offline jobs or failed requests
+	StatusCode int `json:"status_code,omitempty"`
+
+	// TLS describes the connection and certificate of https hosts
+	TLS *TLSInfo `json:"tls,omitempty"`
 }
 
-// Match type encapsulates the App information from a match on a document
+// Match type encapsulates the App information from a match on a document.
+// It is marshalled to JSON in a compact form, see WithFullMatches.
 type Match struct {
-	App     `json:"app"`
-	AppName string     `json:"app_name"`
-	Matches [][]string `json:"matches"`
-	Version string     `json:"version"`
+	App        `json:"app"`
+	AppName    string     `json:"app_name"`
+	Matches    [][]string `json:"matches"`
+	Version    string     `json:"version"`
+	Confidence int        `json:"confidence"`
+	Evidence   []Evidence `json:"evidence,omitempty"`
+	ImpliedBy  []string   `json:"implied_by,omitempty"` // apps which implied this one
+
+	// Vulnerabilities of the detected version, see WithVulnDB
+	Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+
+	full    bool
+	counted map[string]bool // patterns which added to Confidence
+}
+
+// fullMatch is marshalled with the complete app definition
+type fullMatch Match
+
+// MarshalJSON implements json.Marshaler
+func (m Match) MarshalJSON() ([]byte, error) {
+	if m.full {
+		return json.Marshal(struct {
+			fullMatch
+			CPE string `json:"cpe,omitempty"`
+		}{fullMatch(m), m.VersionedCPE()})
+	}
+
+	return json.Marshal(struct {
+		Name        string     `json:"name"`
+		Version     string     `json:"version,omitempty"`
+		Categories  []string   `json:"categories"`
+		Confidence  int        `json:"confidence"`
+		Website     string     `json:"website,omitempty"`
+		CPE         string     `json:"cpe,omitempty"`
+		Icon        string     `json:"icon,omitempty"`
+		Description string     `json:"description,omitempty"`
+		Pricing     []string   `json:"pricing,omitempty"`
+		SaaS        bool       `json:"saas,omitempty"`
+		OSS         bool       `json:"oss,omitempty"`
+		Evidence    []Evidence `json:"evidence,omitempty"`
+		ImpliedBy   []string   `json:"implied_by,omitempty"`
+
+		Vulnerabilities []Vulnerability `json:"vulnerabilities,omitempty"`
+	}{
+		m.AppName, m.Version, m.CatNames, m.Confidence, m.Website,
+		m.VersionedCPE(), m.Icon, m.Description, m.Pricing, m.SaaS, m.OSS,
+		m.Evidence, m.ImpliedBy, m.Vulnerabilities,
+	})
 }
 
 // WebAnalyzer types holds an analyzation job
 type WebAnalyzer struct {
-	appDefs   *AppsDefinition
-	scheduler chan *Job
-	client    *http.Client
+	appDefs      *AppsDefinition
+	client       *http.Client
+	regexpEngine RegexpEngine
+	degraded     []DegradedPattern
+	htmlIndex    *literalIndex // nil evaluates all html patterns
+	dnsTypes     []string      // record types used by dns patterns
+	dns          *lruCache
+	hasRobots    bool // robots.txt is only fetched if patterns use it
+	robots       *lruCache
+
+	// result options, see Option
+	fullMatches bool
+	matchOrder  string
+	vulnDB      *VulnDB
+
+	// request options, see Option
+	headers       http.Header
+	maxBodySize   int64
+	timeout       time.Duration
+	verifyTLS     bool
+	checkRedirect func(req *http.Request, via []*http.Request) error
+	proxy         func(*http.Request) (*url.URL, error)
+	resolver      Resolver
+	fetchRobots   bool
 }
 
-func (m *Match) updateVersion(version string) {
-	if version != "" {
-		m.Version = version
+// update adds evidence to a finding and accumulates its confidence,
+// which is capped at 100. Each pattern adds its confidence once, no matter
+// how many elements, values or scripts it matched.
+func (m *Match) update(evidence ...Evidence) {
+	for _, e := range evidence {
+		m.Evidence = append(m.Evidence, e)
+		m.Matches = append(m.Matches, e.submatches...)
+
+		if betterVersion(e.Version, m.Version) {
+			m.Version = e.Version
+		}
+
+		if m.counted == nil {
+			m.counted = make(map[string]bool)
+		}
+		if id := e.patternID(); !m.counted[id] {
+			m.counted[id] = true
+			m.Confidence += e.Confidence
+		}
+	}
+
+	if m.Confidence > 100 {
+		m.Confidence = 100
 	}
 }
 
-// NewWebAnalyzer initializes webanalyzer by passing a reader of the
-// app definition and an schedulerChan, which allows the scanner to
-// add scan jobs on its own
-func NewWebAnalyzer(apps io.Reader, client *http.Client) (*WebAnalyzer, error) {
-	wa := new(WebAnalyzer)
+// merge adds the evidence and implications of another finding of the same
+// app, keeping the higher confidence of both
+func (m *Match) merge(other Match) {
+	confidence := m.Confidence
+	m.update(other.Evidence...)
+	m.Confidence = confidence
 
-	if err := wa.loadApps(apps); err != nil {
-		return nil, err
+	if other.Confidence > m.Confidence {
+		m.Confidence = other.Confidence
+	}
+
+	if betterVersion(other.Version, m.Version) {
+		m.Version = other.Version
 	}
 
-	wa.client = client
+	if len(other.ImpliedBy) > 0 {
+		m.ImpliedBy = unique(append(m.ImpliedBy, other.ImpliedBy...))
+	}
+}
 
-	return wa, nil
+// NewWebAnalyzer initializes webanalyzer by passing a reader of the
+// app definition and an optional http client. Use a Scanner to process
+// many jobs concurrently.
+func NewWebAnalyzer(apps io.Reader, client *http.Client) (*WebAnalyzer, error) {
+	return NewWebAnalyzerWithOptions(apps, WithHTTPClient(client))
 }
 
 // worker loops until channel is closed. processes a single host at once
 func (wa *WebAnalyzer) Process(job *Job) (Result, []string) {
+	return wa.ProcessContext(context.Background(), job)
+}
+
+// ProcessContext is like Process, but cancels fetching and matching once
+// ctx is done. The Result then carries an error matching ErrCanceled.
+func (wa *WebAnalyzer) ProcessContext(ctx context.Context, job *Job) (Result, []string) {
 
 	// fix missing http scheme
 	u, err := url.Parse(job.URL)
 	if err != nil {
-		return Result{Host: job.URL, Error: err}, []string{}
+		return Result{Host: job.URL, Error: &ScanError{Kind: ErrorKindParse, Err: err}}, []string{}
 	}
 
 	if u.Scheme == "" {
@@ -80,20 +195,45 @@ func (wa *WebAnalyzer) Process(job *Job) (Result, []string) {
 	}
 	job.URL = u.String()
 
+	res := Result{
+		Host: job.URL,
+	}
+
 	// measure time
 	t0 := time.Now()
-	result, links, err := wa.process(job, wa.appDefs)
+	result, links, err := wa.process(ctx, job, wa.appDefs, &res)
 	t1 := time.Now()
 
-	res := Result{
-		Host:     job.URL,
-		Matches:  result,
-		Duration: t1.Sub(t0),
-		Error:    err,
+	for i := range result {
+		result[i].full = wa.fullMatches
+
+		if wa.vulnDB != nil && result[i].Version != "" {
+			result[i].Vulnerabilities = wa.vulnDB.Lookup(result[i].VersionedCPE())
+		}
+	}
+
+	res.Matches = result
+	res.Duration = t1.Sub(t0)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		res.Error = &ScanError{Kind: ErrorKindCanceled, Err: fmt.Errorf("%v: %w", ErrCanceled, ctx.Err())}
+	case err != nil:
+		res.Error = classifyError(err)
+	case res.StatusCode >= 400:
+		// matches of error pages are kept, they often reveal the server
+		res.Error = &ScanError{Kind: ErrorKindHTTPStatus, Err: fmt.Errorf("unexpected status %v", res.StatusCode)}
 	}
+
 	return res, links
 }
 
+// DegradedPatterns returns all patterns of the loaded app definitions which
+// were translated, compiled by the fallback engine or dropped
+func (wa *WebAnalyzer) DegradedPatterns() []DegradedPattern {
+	return wa.degraded
+}
+
 func (wa *WebAnalyzer) CategoryById(cid string) string {
 	if _, ok := wa.appDefs.Cats[cid]; !ok {
 		return ""
@@ -102,36 +242,17 @@ func (wa *WebAnalyzer) CategoryById(cid string) string {
 	return wa.appDefs.Cats[cid].Name
 }
 
-func fetchHost(urlStr string, client *http.Client) (*http.Response, error) {
-	if client == nil {
-		client = &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-				Proxy:           http.ProxyFromEnvironment,
-			},
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				url, err := url.Parse(urlStr)
-				if err != nil {
-					return http.ErrUseLastResponse
-				}
-
-				// allow redirects from http -> https on the same host
-				if url.Hostname() != req.URL.Hostname() {
-					return http.ErrUseLastResponse
-				}
-
-				return nil
-			},
-		}
-	}
-	req, err := http.NewRequest("GET", urlStr, nil)
+// fetch requests urlStr with the configured client and headers
+func (wa *WebAnalyzer) fetch(ctx context.Context, urlStr string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Accept", "*/*")
+	for k, v := range wa.headers {
+		req.Header[k] = append([]string(nil), v...)
+	}
 
-	resp, err := client.Do(req)
+	resp, err := wa.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -206,12 +327,113 @@ func parseLinks(doc *goquery.Document, base *url.URL, searchSubdomain bool) []st
 	return unique(links)
 }
 
+// isJavascript reports whether a script tag contains javascript code
+func isJavascript(s *goquery.Selection) bool {
+	t, ok := s.Attr("type")
+	if !ok || t == "" {
+		return true
+	}
+	t = strings.ToLower(t)
+	return strings.Contains(t, "javascript") || t == "module"
+}
+
+// metaKeys lists the attributes which identify a meta tag
+var metaKeys = []string{"name", "property", "http-equiv", "itemprop"}
+
+// extractMeta collects the content of all meta tags, keyed by their
+// lowercased name, property, http-equiv or itemprop attribute
+func extractMeta(doc *goquery.Document) map[string][]string {
+	meta := make(map[string][]string)
+
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		content, ok := s.Attr("content")
+		if !ok {
+			return
+		}
+
+		for _, attr := range metaKeys {
+			if key, ok := s.Attr(attr); ok && key != "" {
+				key = strings.ToLower(strings.TrimSpace(key))
+				meta[key] = append(meta[key], content)
+			}
+		}
+	})
+
+	return meta
+}
+
+// scriptSources returns the src attributes of all script tags
+func scriptSources(doc *goquery.Document) []string {
+	var sources []string
+
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		if src, ok := s.Attr("src"); ok {
+			sources = append(sources, src)
+		}
+	})
+
+	return sources
+}
+
+// scriptBodies returns the content of all inline scripts and, if enabled
+// for the job, of the external scripts referenced by the document
+func (wa *WebAnalyzer) scriptBodies(ctx context.Context, job *Job, doc *goquery.Document) []string {
+	var bodies []string
+	var sources []string
+
+	doc.Find("script").Each(func(i int, s *goquery.Selection) {
+		if !isJavascript(s) {
+			return
+		}
+		if src, ok := s.Attr("src"); ok {
+			sources = append(sources, src)
+			return
+		}
+		bodies = append(bodies, s.Text())
+	})
+
+	if !job.FetchScripts || job.forceNotDownload {
+		return bodies
+	}
+
+	base, err := url.Parse(job.URL)
+	if err != nil {
+		return bodies
+	}
+
+	for i, src := range unique(sources) {
+		if i >= maxFetchedScripts {
+			break
+		}
+
+		u, err := base.Parse(src)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+
+		resp, err := wa.fetch(ctx, u.String())
+		if err != nil {
+			continue
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScriptSize))
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		bodies = append(bodies, string(body))
+	}
+
+	return bodies
+}
+
 func isSubdomain(base, u *url.URL) bool {
 	return domainutil.Domain(base.String()) == domainutil.Domain(u.String())
 }
 
 // do http request and analyze response
-func (wa *WebAnalyzer) process(job *Job, appDefs *AppsDefinition) ([]Match, []string, error) {
+func (wa *WebAnalyzer) process(ctx context.Context, job *Job, appDefs *AppsDefinition, res *Result) ([]Match, []string, error) {
 	var apps = make([]Match, 0)
 	var err error
 
@@ -220,6 +442,10 @@ func (wa *WebAnalyzer) process(job *Job, appDefs *AppsDefinition) ([]Match, []st
 	var body []byte
 	var headers http.Header
 	var links []string
+	var issuers []string
+	var records map[string][]string
+	var robots string
+	var readErr error
 
 	// get response from host if allowed
 	if job.forceNotDownload {
@@ -227,28 +453,40 @@ func (wa *WebAnalyzer) process(job *Job, appDefs *AppsDefinition) ([]Match, []st
 		headers = job.Headers
 		cookies = job.Cookies
 	} else {
-		resp, err := fetchHost(job.URL, wa.client)
+		resp, err := wa.fetch(ctx, job.URL)
 		if err != nil {
 			return nil, links, fmt.Errorf("Failed to retrieve: %w", err)
 		}
 
 		defer resp.Body.Close()
-
-		body, err = ioutil.ReadAll(resp.Body)
-		if err == nil {
-			headers = resp.Header
-			if job.followRedirect {
-				for k, v := range resp.Header {
-					if k == "Location" {
-						base, _ := url.Parse(job.URL)
-						u := resolveLink(base, v[0], job.SearchSubdomain)
-						if u != "" {
-							links = append(links, v[0])
-						}
+		res.StatusCode = resp.StatusCode
+		res.TLS = newTLSInfo(resp.TLS)
+		issuers = certIssuers(resp.TLS)
+		records = wa.dnsRecords(ctx, job.URL)
+		robots = wa.robotsTxt(ctx, job.URL)
+
+		headers = resp.Header
+		cookies = resp.Cookies()
+		if job.followRedirect {
+			for k, v := range resp.Header {
+				if k == "Location" {
+					base, _ := url.Parse(job.URL)
+					u := resolveLink(base, v[0], job.SearchSubdomain)
+					if u != "" {
+						links = append(links, v[0])
 					}
 				}
 			}
-			cookies = resp.Cookies()
+		}
+
+		// only read bodies which can contain html or scripts. If reading
+		// fails, the part which was read and the headers are still matched
+		// and the error is reported with the matches.
+		if isTextual(resp.Header.Get("Content-Type")) {
+			body, res.Truncated, err = wa.readBody(resp.Body)
+			if err != nil {
+				readErr = fmt.Errorf("Failed to read body: %w", err)
+			}
 		}
 	}
 
@@ -256,9 +494,16 @@ func (wa *WebAnalyzer) process(job *Job, appDefs *AppsDefinition) ([]Match, []st
 		cookiesMap[c.Name] = c.Value
 	}
 
+	// header, cookie and url fingerprints still apply to binary content
+	if contentType := headers.Get("Content-Type"); isTextual(contentType) {
+		body = decodeBody(body, contentType)
+	} else {
+		body = nil
+	}
+
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		return nil, links, err
+		return nil, links, &ScanError{Kind: ErrorKindParse, Err: err}
 	}
 
 	// handle crawling
@@ -274,143 +519,283 @@ func (wa *WebAnalyzer) process(job *Job, appDefs *AppsDefinition) ([]Match, []st
 		}
 	}
 
-	scripts := doc.Find("script")
+	scripts := wa.scriptBodies(ctx, job, doc)
+
+	p := &page{
+		url:       job.URL,
+		body:      string(body),
+		headers:   headers,
+		cookies:   cookiesMap,
+		issuers:   issuers,
+		dns:       records,
+		robots:    robots,
+		doc:       doc,
+		meta:      extractMeta(doc),
+		scriptSrc: scriptSources(doc),
+		scripts:   scripts,
+		js:        extractJSProperties(scripts),
+	}
 
-	for appname, app := range appDefs.Apps {
-		// TODO: Reduce complexity in this for-loop by functionalising out
-		// the sub-loops and checks.
+	if wa.htmlIndex != nil {
+		p.htmlLiterals = wa.htmlIndex.find(p.body)
+	}
 
-		findings := Match{
-			App:     app,
-			AppName: appname,
-			Matches: make([][]string, 0),
-		}
+	detected := make(map[string]int) // index of each detected app in apps
+	var gated []string
 
-		// check raw html
-		if m, v := findMatches(string(body), app.HTMLRegex); len(m) > 0 {
-			findings.Matches = append(findings.Matches, m...)
-			findings.updateVersion(v)
+	var add func(m Match)
+	add = func(m Match) {
+		if i, ok := detected[m.AppName]; ok {
+			apps[i].merge(m)
+			return
 		}
 
-		// check response header
-		headerFindings, version := app.FindInHeaders(headers)
-		findings.Matches = append(findings.Matches, headerFindings...)
-		findings.updateVersion(version)
+		detected[m.AppName] = len(apps)
+		apps = append(apps, m)
 
-		// check url
-		if m, v := findMatches(job.URL, app.URLRegex); len(m) > 0 {
-			findings.Matches = append(findings.Matches, m...)
-			findings.updateVersion(v)
-		}
+		// handle implies, the recursion ends at apps which were already
+		// detected, so cycles are resolved as well
+		for _, implies := range m.App.Implies {
+			name, version, confidence := parsePattern(implies)
 
-		// check script tags
-		scripts.Each(func(i int, s *goquery.Selection) {
-			if script, exists := s.Attr("src"); exists {
-				if m, v := findMatches(script, app.ScriptRegex); len(m) > 0 {
-					findings.Matches = append(findings.Matches, m...)
-					findings.updateVersion(v)
-				}
+			implyApp, ok := appDefs.Apps[name]
+			if !ok {
+				continue
 			}
-		})
-
-		// check meta tags
-		for _, h := range app.MetaRegex {
-			selector := fmt.Sprintf("meta[name='%s']", h.Name)
-			doc.Find(selector).Each(func(i int, s *goquery.Selection) {
-				content, _ := s.Attr("content")
-				if m, v := findMatches(content, []AppRegexp{h}); len(m) > 0 {
-					findings.Matches = append(findings.Matches, m...)
-					findings.updateVersion(v)
-				}
+
+			if confidence > m.Confidence {
+				confidence = m.Confidence
+			}
+
+			add(Match{
+				App:        implyApp,
+				AppName:    name,
+				Matches:    make([][]string, 0),
+				Version:    version,
+				Confidence: confidence,
+				ImpliedBy:  []string{m.AppName},
 			})
 		}
+	}
 
-		// check cookies
-		for _, c := range app.CookieRegex {
-			if _, ok := cookiesMap[c.Name]; ok {
+	// apps are evaluated in sorted order to get deterministic results
+	for _, appname := range appDefs.names {
+		if err := ctx.Err(); err != nil {
+			return nil, links, err
+		}
 
-				// if there is a regexp set, ensure it matches.
-				// otherwise just add this as a match
-				if c.Regexp != nil {
+		app := appDefs.Apps[appname]
 
-					// only match single AppRegexp on this specific cookie
-					if m, v := findMatches(cookiesMap[c.Name], []AppRegexp{c}); len(m) > 0 {
-						findings.Matches = append(findings.Matches, m...)
-						findings.updateVersion(v)
-					}
-
-				} else {
-					findings.Matches = append(findings.Matches, []string{c.Name})
-				}
-			}
+		// apps with requirements are evaluated once these are detected
+		if len(app.Requires) > 0 || len(app.RequiresCategory) > 0 {
+			gated = append(gated, appname)
+			continue
+		}
 
+		if findings := matchApp(appname, app, p); len(findings.Matches) > 0 {
+			add(findings)
 		}
+	}
 
-		if len(findings.Matches) > 0 {
-			apps = append(apps, findings)
+	// evaluate requires-gated apps until no new requirement gets satisfied
+	for progress := true; progress; {
+		progress = false
+		pending := gated[:0]
 
-			// handle implies
-			for _, implies := range app.Implies {
-				for implyAppname, implyApp := range appDefs.Apps {
-					if implies != implyAppname {
-						continue
-					}
+		for _, appname := range gated {
+			if err := ctx.Err(); err != nil {
+				return nil, links, err
+			}
 
-					f2 := Match{
-						App:     implyApp,
-						AppName: implyAppname,
-						Matches: make([][]string, 0),
-					}
-					apps = append(apps, f2)
-				}
+			app := appDefs.Apps[appname]
+			if !requirementsMet(app, apps, detected) {
+				pending = append(pending, appname)
+				continue
+			}
+
+			progress = true
 
+			if findings := matchApp(appname, app, p); len(findings.Matches) > 0 {
+				add(findings)
 			}
 		}
+
+		gated = pending
 	}
 
-	return apps, links, nil
+	apps = resolveExcludes(apps)
+	SortMatches(apps, wa.matchOrder)
+
+	return apps, links, readErr
+}
+
+// page holds the data of a single document analyzed by matchApp
+type page struct {
+	url       string
+	body      string
+	headers   http.Header
+	cookies   map[string]string
+	issuers   []string
+	dns       map[string][]string
+	robots    string
+	doc       *goquery.Document
+	meta      map[string][]string
+	scriptSrc []string
+	scripts   []string
+	js        jsProperties
+
+	// htmlLiterals marks the indexed literals found in body
+	htmlLiterals []bool
 }
 
-// runs a list of regexes on content
-func findMatches(content string, regexes []AppRegexp) ([][]string, string) {
-	var m [][]string
-	var version string
+// htmlCandidates returns the html regexes which can match the body
+// according to the literal index
+func (p *page) htmlCandidates(regexes []AppRegexp) []AppRegexp {
+	if p.htmlLiterals == nil {
+		return regexes
+	}
 
-	for _, r := range regexes {
-		matches := r.Regexp.FindAllStringSubmatch(content, -1)
-		if matches == nil {
+	var candidates []AppRegexp
+	for i, r := range regexes {
+		if !r.mayMatch(p.htmlLiterals) {
+			if candidates == nil {
+				candidates = append(make([]AppRegexp, 0, len(regexes)), regexes[:i]...)
+			}
 			continue
 		}
+		if candidates != nil {
+			candidates = append(candidates, r)
+		}
+	}
+
+	if candidates == nil {
+		return regexes
+	}
+	return candidates
+}
+
+// matchApp runs all fingerprints of an app against a page
+func matchApp(appname string, app App, p *page) Match {
+	findings := Match{
+		App:     app,
+		AppName: appname,
+		Matches: make([][]string, 0),
+	}
+
+	// check raw html
+	findings.update(findMatches(p.body, p.htmlCandidates(app.HTMLRegex), SourceHTML, "")...)
+
+	// check response header
+	findings.update(app.findInHeaders(p.headers)...)
+
+	// check url
+	findings.update(findMatches(p.url, app.URLRegex, SourceURL, "")...)
+
+	// check script urls
+	for _, src := range p.scriptSrc {
+		findings.update(findMatches(src, app.ScriptSrcRegex, SourceScriptSrc, src)...)
+	}
+
+	// check inline and fetched script content
+	for _, script := range p.scripts {
+		findings.update(findMatches(script, app.ScriptRegex, SourceScript, "")...)
+	}
+
+	// check javascript properties
+	findings.update(app.findInJS(p.js)...)
 
-		m = append(m, matches...)
+	// check dom selectors
+	findings.update(app.findInDOM(p.doc)...)
 
-		if r.Version != "" {
-			version = findVersion(m, r.Version)
+	// check meta tags
+	findings.update(app.findInMeta(p.meta)...)
+
+	// check certificate issuers
+	for _, issuer := range p.issuers {
+		findings.update(findMatches(issuer, app.CertIssuerRegex, SourceCertIssuer, "")...)
+	}
+
+	// check dns records of the domain
+	findings.update(app.findInDNS(p.dns)...)
+
+	// check robots.txt of the origin
+	if p.robots != "" {
+		findings.update(findMatches(p.robots, app.RobotsRegex, SourceRobots, "")...)
+	}
+
+	// check cookies
+	for _, c := range app.CookieRegex {
+		if _, ok := p.cookies[c.Name]; ok {
+
+			// if there is a regexp set, ensure it matches.
+			// otherwise just add this as a match
+			if c.Regexp != nil {
+
+				// only match single AppRegexp on this specific cookie
+				findings.update(findMatches(p.cookies[c.Name], []AppRegexp{c}, SourceCookie, c.Name)...)
+
+			} else {
+				findings.update(Evidence{
+					Source:     SourceCookie,
+					Key:        c.Name,
+					Match:      c.Name,
+					Confidence: c.Confidence,
+					submatches: [][]string{{c.Name}},
+				})
+			}
 		}
 
 	}
-	return m, version
+
+	return findings
 }
 
-// parses a version against matches
-func findVersion(matches [][]string, version string) string {
-	var v string
+// requirementsMet reports whether all technologies and at least one of
+// the categories required by an app have been detected
+func requirementsMet(app App, apps []Match, detected map[string]int) bool {
+	for _, name := range app.Requires {
+		if _, ok := detected[name]; !ok {
+			return false
+		}
+	}
+
+	if len(app.RequiresCategory) == 0 {
+		return true
+	}
 
-	for _, matchPair := range matches {
-		// replace backtraces (max: 3)
-		for i := 1; i <= 3; i++ {
-			bt := fmt.Sprintf("\\%v", i)
-			if strings.Contains(version, bt) && len(matchPair) >= i {
-				v = strings.Replace(version, bt, matchPair[i], 1)
+	for _, m := range apps {
+		for _, cid := range m.App.Cats {
+			for _, required := range app.RequiresCategory {
+				if cid == required {
+					return true
+				}
 			}
 		}
+	}
+
+	return false
+}
 
-		// return first found version
-		if v != "" {
-			return v
+// resolveExcludes removes all apps which are excluded by another detected app
+func resolveExcludes(apps []Match) []Match {
+	excluded := make(map[string]bool)
+	for _, m := range apps {
+		for _, name := range m.App.Excludes {
+			if name != m.AppName {
+				excluded[name] = true
+			}
 		}
+	}
 
+	if len(excluded) == 0 {
+		return apps
 	}
 
-	return ""
+	res := make([]Match, 0, len(apps))
+	for _, m := range apps {
+		if !excluded[m.AppName] {
+			res = append(res, m)
+		}
+	}
+	return res
 }
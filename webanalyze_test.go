@@ -1,6 +1,9 @@
 package webanalyze
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
@@ -88,3 +91,260 @@ func TestIsSubdomain(t *testing.T) {
 		t.Fatalf("%v is not a subdomain of %v (but should be)", u2, u1)
 	}
 }
+
+func newTestAnalyzer(t *testing.T, technologies string) *WebAnalyzer {
+	defs := `{"categories": {"1": {"name": "CMS"}, "2": {"name": "Plugins"}}, "technologies": ` + technologies + `}`
+
+	wa, err := NewWebAnalyzer(strings.NewReader(defs), nil)
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+	return wa
+}
+
+func matchNames(matches []Match) map[string]bool {
+	names := make(map[string]bool)
+	for _, m := range matches {
+		names[m.AppName] = true
+	}
+	return names
+}
+
+func TestProcessRelationships(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"WordPress": {"cats": [1], "html": "wp-content", "excludes": "Drupal"},
+		"Drupal": {"cats": [1], "html": "drupal"},
+		"WooCommerce": {"cats": [2], "html": "woocommerce", "requires": "WordPress"},
+		"Elementor": {"cats": [2], "html": "elementor", "requiresCategory": 1},
+		"Yoast": {"cats": [2], "html": "yoast", "requires": "Joomla"}
+	}`)
+
+	body := `<html><body>wp-content drupal woocommerce elementor yoast</body></html>`
+	job := NewOfflineJob("http://example.com", body, nil)
+
+	result, _ := wa.Process(job)
+	if result.Error != nil {
+		t.Fatalf("process failed: %v", result.Error)
+	}
+
+	names := matchNames(result.Matches)
+	for _, expected := range []string{"WordPress", "WooCommerce", "Elementor"} {
+		if !names[expected] {
+			t.Fatalf("%v not detected", expected)
+		}
+	}
+
+	for _, unexpected := range []string{"Drupal", "Yoast"} {
+		if names[unexpected] {
+			t.Fatalf("%v should not be detected", unexpected)
+		}
+	}
+}
+
+func TestProcessConfidenceRepeatedMatches(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"Weak": {"cats": [1], "scriptSrc": "weak\\.js\\;confidence:25"},
+		"Widget": {"cats": [1], "dom": {"div.widget": {"attributes": {"data-v": "\\d\\;confidence:30"}}}, "meta": {"widget": "on\\;confidence:20"}}
+	}`)
+
+	body := `<html><head><meta name="widget" content="on"><meta name="widget" content="on"></head><body>
+		<script src="/a/weak.js"></script><script src="/b/weak.js"></script>
+		<script src="/c/weak.js"></script><script src="/d/weak.js"></script>
+		<div class="widget" data-v="1"></div><div class="widget" data-v="2"></div>
+	</body></html>`
+
+	result, _ := wa.Process(NewOfflineJob("http://example.com", body, nil))
+
+	expected := map[string]int{"Weak": 25, "Widget": 50}
+	for _, m := range result.Matches {
+		if m.Confidence != expected[m.AppName] {
+			t.Fatalf("%v: expected confidence %v, got %v", m.AppName, expected[m.AppName], m.Confidence)
+		}
+	}
+
+	if len(result.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", matchNames(result.Matches))
+	}
+}
+
+func TestProcessConfidence(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"Foo": {"cats": [1], "html": ["foo-a\\;confidence:25", "foo-b\\;confidence:50"]},
+		"Bar": {"cats": [1], "html": ["bar-([\\d.]+)\\;version:\\1\\;confidence:80"], "headers": {"X-Bar": ""}}
+	}`)
+
+	body := `<html><body>foo-a foo-b bar-1.2</body></html>`
+	job := NewOfflineJob("http://example.com", body, map[string][]string{"X-Bar": {"1"}})
+
+	result, _ := wa.Process(job)
+
+	expected := map[string]int{"Foo": 75, "Bar": 100}
+	for _, m := range result.Matches {
+		if m.Confidence != expected[m.AppName] {
+			t.Fatalf("%v: expected confidence %v, got %v", m.AppName, expected[m.AppName], m.Confidence)
+		}
+		if m.AppName == "Bar" && m.Version != "1.2" {
+			t.Fatalf("expected version 1.2, got %v", m.Version)
+		}
+	}
+
+	if len(result.Matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", len(result.Matches))
+	}
+}
+
+func TestProcessScripts(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"jQuery": {"cats": [1], "scriptSrc": "jquery[.-]([\\d.]+)\\.js\\;version:\\1"},
+		"Inline": {"cats": [1], "scripts": "inlineTracker\\("},
+		"Wrong": {"cats": [1], "scripts": "jquery"}
+	}`)
+
+	body := `<html><head>
+	<script src="/static/jquery-3.6.0.js"></script>
+	<script>inlineTracker("init");</script>
+	</head></html>`
+	job := NewOfflineJob("http://example.com", body, nil)
+
+	result, _ := wa.Process(job)
+
+	names := matchNames(result.Matches)
+	if !names["jQuery"] || !names["Inline"] {
+		t.Fatalf("script fingerprints not detected: %v", names)
+	}
+	if names["Wrong"] {
+		t.Fatalf("script content pattern matched a script url")
+	}
+
+	for _, m := range result.Matches {
+		if m.AppName == "jQuery" && m.Version != "3.6.0" {
+			t.Fatalf("expected version 3.6.0, got %v", m.Version)
+		}
+	}
+}
+
+func TestProcessMeta(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"WordPress": {"cats": [1], "meta": {"generator": ["^WordPress ?([\\d.]+)?\\;version:\\1"]}},
+		"Open Graph": {"cats": [1], "meta": {"og:title": ""}},
+		"Refresh": {"cats": [1], "meta": {"Refresh": "url="}},
+		"Quoted": {"cats": [1], "meta": {"it's": ""}}
+	}`)
+
+	body := `<html><head>
+	<meta name="Generator" content="WordPress 6.1">
+	<meta property="og:title" content="Example">
+	<meta http-equiv="refresh" content="0; URL=/home">
+	<meta name="it's" content="quoted">
+	</head></html>`
+	job := NewOfflineJob("http://example.com", body, nil)
+
+	result, _ := wa.Process(job)
+
+	if len(result.Matches) != 4 {
+		t.Fatalf("expected 4 matches, got %v", len(result.Matches))
+	}
+
+	for _, m := range result.Matches {
+		if m.AppName == "WordPress" && m.Version != "6.1" {
+			t.Fatalf("expected version 6.1, got %v", m.Version)
+		}
+	}
+}
+
+func TestProcessContextCanceled(t *testing.T) {
+	wa := newTestAnalyzer(t, `{"Foo": {"cats": [1], "html": "foo"}}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, _ := wa.ProcessContext(ctx, NewOfflineJob("http://example.com", "foo", nil))
+
+	if !errors.Is(result.Error, ErrCanceled) || !errors.Is(result.Error, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", result.Error)
+	}
+	if len(result.Matches) != 0 {
+		t.Fatalf("canceled job returned matches")
+	}
+}
+
+func TestMatchJSON(t *testing.T) {
+	defs := `{"categories": {"1": {"name": "CMS"}}, "technologies": {
+		"WordPress": {"cats": [1], "html": "wp-content", "website": "https://wordpress.org"}
+	}}`
+	job := NewOfflineJob("http://example.com", "wp-content", nil)
+
+	wa, err := NewWebAnalyzerWithOptions(strings.NewReader(defs))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+	result, _ := wa.Process(job)
+
+	b, err := json.Marshal(result.Matches)
+	if err != nil {
+		t.Fatalf("could not marshal matches: %v", err)
+	}
+	expected := `[{"name":"WordPress","categories":["CMS"],"confidence":100,"website":"https://wordpress.org",` +
+		`"evidence":[{"source":"html","pattern":"wp-content","match":"wp-content","confidence":100}]}]`
+	if string(b) != expected {
+		t.Fatalf("expected %s, got %s", expected, b)
+	}
+
+	wa, err = NewWebAnalyzerWithOptions(strings.NewReader(defs), WithFullMatches(true))
+	if err != nil {
+		t.Fatalf("could not load technologies: %v", err)
+	}
+	result, _ = wa.Process(job)
+
+	b, err = json.Marshal(result.Matches)
+	if err != nil {
+		t.Fatalf("could not marshal matches: %v", err)
+	}
+	if !strings.Contains(string(b), `"app":{"cats":["1"]`) || !strings.Contains(string(b), `"html":["wp-content"]`) {
+		t.Fatalf("expected full app definition, got %s", b)
+	}
+}
+
+func TestProcessImplies(t *testing.T) {
+	wa := newTestAnalyzer(t, `{
+		"WordPress": {"cats": [1], "html": "wp-content", "implies": ["PHP\\;version:8\\;confidence:50", "MySQL"]},
+		"PHP": {"cats": [1], "implies": "Zend"},
+		"Zend": {"cats": [1]},
+		"MySQL": {"cats": [1], "headers": {"X-DB": "mysql"}},
+		"JsRender": {"cats": [2], "html": "jsrender", "implies": "JsViews"},
+		"JsViews": {"cats": [2], "implies": "JsRender"}
+	}`)
+
+	body := `<html><body>wp-content jsrender</body></html>`
+	job := NewOfflineJob("http://example.com", body, map[string][]string{"X-DB": {"mysql"}})
+
+	result, _ := wa.Process(job)
+
+	expected := map[string]struct {
+		version    string
+		confidence int
+		impliedBy  string
+	}{
+		"WordPress": {"", 100, ""},
+		"PHP":       {"8", 50, "WordPress"},
+		"Zend":      {"", 50, "PHP"},
+		"MySQL":     {"", 100, "WordPress"},
+		"JsRender":  {"", 100, "JsViews"},
+		"JsViews":   {"", 100, "JsRender"},
+	}
+
+	if len(result.Matches) != len(expected) {
+		t.Fatalf("expected %v matches, got %v", len(expected), len(result.Matches))
+	}
+
+	for _, m := range result.Matches {
+		e, ok := expected[m.AppName]
+		if !ok {
+			t.Fatalf("unexpected match %v", m.AppName)
+		}
+		if m.Version != e.version || m.Confidence != e.confidence || strings.Join(m.ImpliedBy, ",") != e.impliedBy {
+			t.Fatalf("%v: expected %+v, got version %v, confidence %v, implied by %v",
+				m.AppName, e, m.Version, m.Confidence, m.ImpliedBy)
+		}
+	}
+}